@@ -6,6 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/tidwall/sjson"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
@@ -58,3 +59,30 @@ func TimeDifferenceLess(t testing.TB, t1, t2 time.Time, seconds int) {
 	t.Helper()
 	assert.WithinDuration(t, t1, t2, time.Duration(seconds)*time.Second)
 }
+
+// NoSecretsLeaked fails the test if the captured log output contains
+// any of the secrets, either verbatim, JSON escaped, or URL encoded.
+func NoSecretsLeaked(t testing.TB, output string, secrets ...string) bool {
+	t.Helper()
+
+	ok := true
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+
+		encoded, _ := json.Marshal(secret)
+		for _, variant := range []string{
+			secret,
+			strings.Trim(string(encoded), `"`),
+			url.QueryEscape(secret),
+			url.PathEscape(secret),
+		} {
+			if !assert.NotContains(t, output, variant, "the log output contains the secret %q", secret) {
+				ok = false
+				break
+			}
+		}
+	}
+	return ok
+}
@@ -88,6 +88,19 @@ func WithLogrusWatcher(l *logrusx.Logger) OptionModifier {
 	return AttachWatcher(LogrusWatcher(l))
 }
 
+// WithLogrusConfig applies the "log" config to the logger whenever the
+// configuration was reloaded successfully, so that e.g. log level changes take
+// effect without a restart.
+func WithLogrusConfig(l *logrusx.Logger) OptionModifier {
+	return func(p *Provider) {
+		p.onChanges = append(p.onChanges, func(_ watcherx.Event, err error) {
+			if err == nil {
+				l.UseConfig(p)
+			}
+		})
+	}
+}
+
 func LogrusWatcher(l *logrusx.Logger) func(e watcherx.Event, err error) {
 	return func(e watcherx.Event, err error) {
 		l.WithField("file", e.Source()).
@@ -20,6 +20,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 
+	"github.com/huanggze/x/httpx"
 	"github.com/huanggze/x/logrusx"
 	"github.com/huanggze/x/otelx"
 	"github.com/huanggze/x/watcherx"
@@ -471,6 +472,20 @@ func (p *Provider) TracingConfig(serviceName string) *otelx.Config {
 	}
 }
 
+// ClientIPConfig returns the client IP configuration below the prefix, e.g.
+// "serve.public". The schema can be referenced using
+// {"$ref": "ory://client-ip-config#"}.
+func (p *Provider) ClientIPConfig(prefix string) *httpx.ClientIPConfig {
+	if len(prefix) > 0 {
+		prefix = strings.TrimRight(prefix, ".") + "."
+	}
+
+	return &httpx.ClientIPConfig{
+		TrustedProxies: p.StringsF(prefix+"client_ip.trusted_proxies", []string{}),
+		Headers:        p.StringsF(prefix+"client_ip.headers", httpx.DefaultClientIPHeaders),
+	}
+}
+
 func (p *Provider) RequestURIF(path string, fallback *url.URL) *url.URL {
 	p.l.RLock()
 	defer p.l.RUnlock()
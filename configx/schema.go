@@ -8,6 +8,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
 
+	"github.com/huanggze/x/httpx"
 	"github.com/huanggze/x/logrusx"
 	"github.com/huanggze/x/otelx"
 	"github.com/ory/jsonschema/v3"
@@ -33,6 +34,9 @@ func newCompiler(schema []byte) (string, *jsonschema.Compiler, error) {
 	if err := logrusx.AddConfigSchema(compiler); err != nil {
 		return "", nil, err
 	}
+	if err := httpx.AddClientIPConfigSchema(compiler); err != nil {
+		return "", nil, err
+	}
 
 	return id, compiler, nil
 }
@@ -0,0 +1,269 @@
+package decoderx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ory/herodot"
+	"github.com/ory/jsonschema/v3"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/httpx"
+)
+
+const (
+	// DefaultMaxDecompressedBodySize is the limit of decompressed request
+	// bodies if no limit was set with HTTPDecoderMaxBodySize.
+	DefaultMaxDecompressedBodySize = 32 << 20
+	// DefaultMaxCompressionRatio is the default limit of the ratio between
+	// the decompressed and the compressed size of request bodies.
+	DefaultMaxCompressionRatio = 100
+
+	// compressionRatioSlack is the number of decompressed bytes which are
+	// allowed regardless of the compression ratio, because small payloads
+	// compress well.
+	compressionRatioSlack = 64 << 10
+)
+
+type (
+	// bodyLimitError is returned when reading the request body exceeds a
+	// limit.
+	bodyLimitError struct {
+		reason string
+	}
+
+	compressionRatioReader struct {
+		r          io.Reader
+		compressed *countingReader
+		n          int64
+		ratio      int64
+	}
+
+	readCloser struct {
+		io.Reader
+		io.Closer
+	}
+)
+
+func (e *bodyLimitError) Error() string {
+	return e.reason
+}
+
+// HTTPDecoderMaxBodySize limits the size of the decoded request body. Larger
+// bodies are rejected with ErrPayloadTooLarge. For compressed bodies, the
+// limit applies to the decompressed size. Defaults to no limit for
+// uncompressed and DefaultMaxDecompressedBodySize for compressed bodies.
+func HTTPDecoderMaxBodySize(size int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxBodySize = size
+	}
+}
+
+// HTTPDecoderMaxCompressionRatio limits the ratio between the decompressed and
+// the compressed size of request bodies to protect against decompression
+// bombs. A ratio of zero disables the check. Defaults to
+// DefaultMaxCompressionRatio.
+func HTTPDecoderMaxCompressionRatio(ratio int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxCompressionRatio = ratio
+	}
+}
+
+func (r *compressionRatioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.n > compressionRatioSlack && r.n > r.compressed.n*r.ratio {
+		return n, errors.WithStack(&bodyLimitError{reason: fmt.Sprintf("The request body exceeds the compression ratio limit of %d.", r.ratio)})
+	}
+	return n, err
+}
+
+// prepareBody replaces the request body with a reader which decompresses
+// gzip and deflate encoded bodies and enforces the body size limits.
+func (t *HTTP) prepareBody(r *http.Request, o *httpDecoderOptions) error {
+	if strings.ToUpper(r.Method) == "GET" || r.Body == nil {
+		return nil
+	}
+
+	limit := o.maxBodySize
+	if limit > 0 && r.ContentLength > limit {
+		return errors.WithStack(ErrPayloadTooLarge.WithReasonf("The request body exceeds the size limit of %d bytes.", limit))
+	}
+
+	var body io.Reader = r.Body
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "", "identity":
+	case "gzip", "x-gzip", "deflate":
+		compressed := &countingReader{r: r.Body}
+
+		var err error
+		if encoding == "deflate" {
+			body, err = zlib.NewReader(compressed)
+		} else {
+			body, err = gzip.NewReader(compressed)
+		}
+		if err != nil {
+			return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decompress the %s encoded HTTP body: %s", encoding, err).WithDebug(err.Error()))
+		}
+
+		if o.maxCompressionRatio > 0 {
+			body = &compressionRatioReader{r: body, compressed: compressed, ratio: o.maxCompressionRatio}
+		}
+		if limit <= 0 {
+			limit = DefaultMaxDecompressedBodySize
+		}
+
+		r.Header.Del("Content-Encoding")
+		r.Header.Del("Content-Length")
+		r.ContentLength = -1
+	default:
+		return errors.WithStack(herodot.ErrUnsupportedMediaType.WithReasonf(`HTTP Header "Content-Encoding: %s" is not supported, only gzip and deflate are.`, encoding))
+	}
+
+	if limit > 0 {
+		body = &sizeLimitReader{r: body, n: limit, err: &bodyLimitError{
+			reason: fmt.Sprintf("The request body exceeds the size limit of %d bytes.", limit),
+		}}
+	}
+
+	r.Body = readCloser{Reader: body, Closer: r.Body}
+	return nil
+}
+
+// readError returns ErrPayloadTooLarge if reading the request body exceeded a
+// limit and herodot.ErrBadRequest with the reason otherwise.
+func readError(err error, format string, args ...interface{}) error {
+	var limitErr *bodyLimitError
+	if errors.As(err, &limitErr) {
+		return errors.WithStack(ErrPayloadTooLarge.WithReason(limitErr.reason))
+	}
+	return errors.WithStack(herodot.ErrBadRequest.WithReasonf(format, args...).WithDebug(err.Error()))
+}
+
+// DecodeArray decodes a JSON array request body element by element without
+// buffering the whole payload. Each element is validated against the "items"
+// schema, and "minItems" and "maxItems" are checked, but other keywords of
+// the array schema are ignored. fn is called with each valid element and may
+// unmarshal it. Decoding stops at the first error.
+func (t *HTTP) DecodeArray(r *http.Request, fn func(index int, element json.RawMessage) error, opts ...HTTPDecoderOption) error {
+	o := newHTTPDecoderOptions(opts)
+	if err := t.validateRequest(r, o); err != nil {
+		return err
+	}
+
+	if !httpx.HasContentType(r, httpContentTypeJSON) {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf(`HTTP %s Request used unknown HTTP Header "Content-Type: %s", only %s is supported.`, strings.ToUpper(r.Method), r.Header.Get("Content-Type"), httpContentTypeJSON))
+	}
+
+	if err := t.prepareBody(r, o); err != nil {
+		return err
+	}
+
+	var schema *jsonschema.Schema
+	if o.jsonSchemaValidate {
+		if o.jsonSchemaCompiler == nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("JSON Schema Validation is required but no compiler was provided."))
+		}
+
+		var err error
+		schema, err = o.jsonSchemaCompiler.Compile(r.Context(), o.jsonSchemaRef)
+		if err != nil {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to load JSON Schema from location: %s", o.jsonSchemaRef).WithDebug(err.Error()))
+		}
+	}
+
+	dec := json.NewDecoder(r.Body)
+	token, err := dec.Token()
+	if err != nil {
+		return readError(err, "Unable to decode JSON payload: %s", err)
+	} else if token != json.Delim('[') {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected JSON sent in request body to be an array."))
+	}
+
+	index := 0
+	for ; dec.More(); index++ {
+		if schema != nil && schema.MaxItems != -1 && index >= schema.MaxItems {
+			return errors.WithStack(NewValidationError(&jsonschema.ValidationError{
+				Message:     fmt.Sprintf("maximum %d items allowed", schema.MaxItems),
+				InstancePtr: "#",
+				SchemaPtr:   "#/maxItems",
+			}, nil, false))
+		}
+
+		var element json.RawMessage
+		if err := dec.Decode(&element); err != nil {
+			return readError(err, "Unable to decode JSON payload: %s", err)
+		}
+
+		if err := validateArrayItem(schema, index, element); err != nil {
+			return err
+		}
+
+		if err := fn(index, element); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return readError(err, "Unable to decode JSON payload: %s", err)
+	}
+
+	if schema != nil && schema.MinItems != -1 && index < schema.MinItems {
+		return errors.WithStack(NewValidationError(&jsonschema.ValidationError{
+			Message:     fmt.Sprintf("minimum %d items allowed, but found %d items", schema.MinItems, index),
+			InstancePtr: "#",
+			SchemaPtr:   "#/minItems",
+		}, nil, false))
+	}
+
+	return nil
+}
+
+func validateArrayItem(schema *jsonschema.Schema, index int, element json.RawMessage) error {
+	if schema == nil {
+		return nil
+	}
+
+	var items *jsonschema.Schema
+	switch i := schema.Items.(type) {
+	case *jsonschema.Schema:
+		items = i
+	case []*jsonschema.Schema:
+		if index < len(i) {
+			items = i[index]
+		} else if additional, ok := schema.AdditionalItems.(*jsonschema.Schema); ok {
+			items = additional
+		} else if allowed, ok := schema.AdditionalItems.(bool); ok && !allowed {
+			return errors.WithStack(NewValidationError(&jsonschema.ValidationError{
+				Message:     fmt.Sprintf("only %d items are allowed, but found more items", len(i)),
+				InstancePtr: "#",
+				SchemaPtr:   "#/additionalItems",
+			}, nil, false))
+		}
+	}
+	if items == nil {
+		return nil
+	}
+
+	if err := items.Validate(bytes.NewReader(element)); err != nil {
+		var ve *jsonschema.ValidationError
+		if !errors.As(err, &ve) {
+			return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to process JSON Schema and input: %s", err).WithDebug(err.Error()))
+		}
+
+		e := NewValidationError(ve, element, false)
+		for k := range e.Fields {
+			e.Fields[k].Pointer = "/" + strconv.Itoa(index) + e.Fields[k].Pointer
+		}
+		return errors.WithStack(e)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package decoderx
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/tidwall/gjson"
+
+	"github.com/huanggze/x/jsonschemax"
+)
+
+type (
+	// FieldError describes why a single field failed validation.
+	FieldError struct {
+		// Pointer is the RFC 6901 JSON Pointer of the invalid field, e.g.
+		// "/traits/email". The root is "".
+		Pointer string `json:"pointer"`
+		// Field is the form field name, e.g. "traits.email". It is only set
+		// for form requests.
+		Field string `json:"field,omitempty"`
+		// Keyword is the JSON Schema keyword which failed, e.g. "minLength".
+		Keyword string `json:"keyword"`
+		// MessageID identifies the message for translation, e.g.
+		// "validation.minLength".
+		MessageID string `json:"id"`
+		// Message is the human readable message.
+		Message string `json:"message"`
+		// Context holds values which can be used in translated messages.
+		Context map[string]interface{} `json:"context,omitempty"`
+	}
+
+	// ValidationError is returned when a decoded payload does not match the
+	// JSON Schema. It renders as a herodot error with status 400 and the field
+	// errors as details. The *jsonschema.ValidationError it was created from
+	// can be retrieved with errors.As.
+	ValidationError struct {
+		Fields []FieldError
+		err    *jsonschema.ValidationError
+	}
+
+	// MessageCatalog looks up translated message templates.
+	MessageCatalog interface {
+		// Message returns the template of the message in the language.
+		Message(language, id string) (template string, ok bool)
+	}
+
+	// MapMessageCatalog is a MessageCatalog keyed by language and message ID.
+	// Templates reference context values as "{name}", e.g.
+	// "{property} is required".
+	MapMessageCatalog map[string]map[string]string
+)
+
+var _ MessageCatalog = MapMessageCatalog(nil)
+
+// Message implements MessageCatalog.
+func (c MapMessageCatalog) Message(language, id string) (string, bool) {
+	template, ok := c[language][id]
+	return template, ok
+}
+
+// NewValidationError creates a ValidationError from a JSON Schema validation
+// error of the payload. If isForm is true, the form field names are set.
+func NewValidationError(err *jsonschema.ValidationError, payload []byte, isForm bool) *ValidationError {
+	e := &ValidationError{err: err}
+	e.collect(err, payload, isForm)
+	return e
+}
+
+func (e *ValidationError) collect(err *jsonschema.ValidationError, payload []byte, isForm bool) {
+	if len(err.Causes) > 0 {
+		for _, cause := range err.Causes {
+			e.collect(cause, payload, isForm)
+		}
+		return
+	}
+
+	keyword := err.SchemaPtr
+	if i := strings.LastIndex(keyword, "/"); i >= 0 {
+		keyword = keyword[i+1:]
+	}
+
+	if required, ok := err.Context.(*jsonschema.ValidationErrorContextRequired); ok && len(required.Missing) > 0 {
+		for _, missing := range required.Missing {
+			property := missing[strings.LastIndex(missing, "/")+1:]
+			e.add(missing, keyword, fmt.Sprintf("property %s is missing", property), map[string]interface{}{
+				"property": property,
+			}, isForm)
+		}
+		return
+	}
+
+	ctx := map[string]interface{}{}
+	if path, convErr := jsonschemax.JSONPointerToDotNotation(err.InstancePtr); convErr == nil && path != "" {
+		if value := gjson.GetBytes(payload, path); value.Exists() {
+			ctx["value"] = value.Value()
+		}
+	} else if err.InstancePtr == "#" {
+		ctx["value"] = gjson.ParseBytes(payload).Value()
+	}
+	e.add(err.InstancePtr, keyword, err.Message, ctx, isForm)
+}
+
+func (e *ValidationError) add(instancePtr, keyword, message string, ctx map[string]interface{}, isForm bool) {
+	f := FieldError{
+		Pointer:   strings.TrimPrefix(instancePtr, "#"),
+		Keyword:   keyword,
+		MessageID: "validation." + keyword,
+		Message:   message,
+	}
+	if len(ctx) > 0 {
+		f.Context = ctx
+	}
+	if isForm && f.Pointer != "" {
+		if field, err := jsonschemax.JSONPointerToDotNotation(instancePtr); err == nil {
+			f.Field = field
+		}
+	}
+	e.Fields = append(e.Fields, f)
+}
+
+// Translate returns a copy of the error with the messages translated to the
+// first language of the catalog which has a template for the message.
+// Untranslated messages are kept.
+func (e *ValidationError) Translate(c MessageCatalog, languages ...string) *ValidationError {
+	translated := &ValidationError{err: e.err, Fields: make([]FieldError, len(e.Fields))}
+	for k, f := range e.Fields {
+		for _, language := range languages {
+			template, ok := c.Message(language, f.MessageID)
+			if !ok {
+				continue
+			}
+
+			replacements := make([]string, 0, 2*len(f.Context))
+			for key, value := range f.Context {
+				replacements = append(replacements, "{"+key+"}", fmt.Sprint(value))
+			}
+			f.Message = strings.NewReplacer(replacements...).Replace(template)
+			break
+		}
+		translated.Fields[k] = f
+	}
+	return translated
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Fields))
+	for k, f := range e.Fields {
+		messages[k] = fmt.Sprintf("%s: %s", f.Pointer, f.Message)
+	}
+	return "the request payload is invalid: " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns http.StatusBadRequest.
+func (e *ValidationError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Status returns the status text.
+func (e *ValidationError) Status() string {
+	return http.StatusText(http.StatusBadRequest)
+}
+
+// Reason returns a summary of the error.
+func (e *ValidationError) Reason() string {
+	return "The request payload is invalid."
+}
+
+// Details returns the field errors.
+func (e *ValidationError) Details() map[string]interface{} {
+	return map[string]interface{}{"fields": e.Fields}
+}
@@ -38,6 +38,14 @@ type (
 		handleParseErrors         parseErrorStrategy
 		expectJSONFlattened       bool
 		queryAndBody              bool
+		fileSink                  FileSink
+		fileFormats               []string
+		allowedFileTypes          []string
+		maxFileSize               int64
+		maxUploadSize             int64
+		patchTarget               json.RawMessage
+		maxBodySize               int64
+		maxCompressionRatio       int64
 	}
 
 	// HTTPDecoderOption configures the HTTP decoder.
@@ -145,6 +153,10 @@ func newHTTPDecoderOptions(fs []HTTPDecoderOption) *httpDecoderOptions {
 		allowedHTTPMethods:        []string{"POST", "PUT", "PATCH"},
 		maxCircularReferenceDepth: 5,
 		handleParseErrors:         ParseErrorIgnoreConversionErrors,
+		fileFormats:               []string{"binary"},
+		maxFileSize:               DefaultMaxFileSize,
+		maxUploadSize:             DefaultMaxUploadSize,
+		maxCompressionRatio:       DefaultMaxCompressionRatio,
 	}
 
 	for _, f := range fs {
@@ -179,7 +191,7 @@ func (t *HTTP) validateRequest(r *http.Request, c *httpDecoderOptions) error {
 	return nil
 }
 
-func (t *HTTP) validatePayload(ctx context.Context, raw json.RawMessage, c *httpDecoderOptions) error {
+func (t *HTTP) validatePayload(ctx context.Context, raw json.RawMessage, c *httpDecoderOptions, isForm bool) error {
 	if !c.jsonSchemaValidate {
 		return nil
 	}
@@ -194,8 +206,8 @@ func (t *HTTP) validatePayload(ctx context.Context, raw json.RawMessage, c *http
 	}
 
 	if err := schema.Validate(bytes.NewBuffer(raw)); err != nil {
-		if _, ok := err.(*jsonschema.ValidationError); ok {
-			return errors.WithStack(err)
+		if ve, ok := err.(*jsonschema.ValidationError); ok {
+			return errors.WithStack(NewValidationError(ve, raw, isForm))
 		}
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to process JSON Schema and input: %s", err).WithDebug(err.Error()))
 	}
@@ -210,6 +222,10 @@ func (t *HTTP) Decode(r *http.Request, destination interface{}, opts ...HTTPDeco
 		return err
 	}
 
+	if err := t.prepareBody(r, c); err != nil {
+		return err
+	}
+
 	if r.Method == "GET" {
 		return t.decodeForm(r, destination, c)
 	} else if httpx.HasContentType(r, httpContentTypeJSON) {
@@ -219,6 +235,10 @@ func (t *HTTP) Decode(r *http.Request, destination interface{}, opts ...HTTPDeco
 		return t.decodeJSON(r, destination, c)
 	} else if httpx.HasContentType(r, httpContentTypeMultipartForm, httpContentTypeURLEncodedForm) {
 		return t.decodeForm(r, destination, c)
+	} else if httpx.HasContentType(r, yamlContentTypes...) {
+		return t.decodeYAML(r, destination, c)
+	} else if httpx.HasContentType(r, httpContentTypeMergePatch, httpContentTypeJSONPatch) {
+		return t.decodePatch(r, destination, c)
 	}
 
 	return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to determine decoder for content type: %s", r.Header.Get("Content-Type")))
@@ -234,7 +254,9 @@ func (t *HTTP) requestBody(r *http.Request, o *httpDecoderOptions) (reader io.Re
 	}
 
 	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
+	if errors.As(err, new(*bodyLimitError)) {
+		return nil, readError(err, "")
+	} else if err != nil {
 		return nil, errors.Wrapf(err, "unable to read body")
 	}
 
@@ -260,7 +282,9 @@ func (t *HTTP) decodeJSONForm(r *http.Request, destination interface{}, o *httpD
 	}
 
 	var interim json.RawMessage
-	if err := json.NewDecoder(reader).Decode(&interim); err != nil {
+	if err := json.NewDecoder(reader).Decode(&interim); errors.As(err, new(*bodyLimitError)) {
+		return readError(err, "")
+	} else if err != nil {
 		return errors.WithStack(herodot.ErrBadRequest.WithError(err.Error()).WithReason("Unable to decode form as JSON."))
 	}
 
@@ -291,7 +315,7 @@ func (t *HTTP) decodeJSONForm(r *http.Request, destination interface{}, o *httpD
 		return errors.WithStack(err)
 	}
 
-	return t.validatePayload(r.Context(), raw, o)
+	return t.validatePayload(r.Context(), raw, o, true)
 }
 
 func (t *HTTP) decodeURLValues(values url.Values, paths []jsonschemax.Path, o *httpDecoderOptions) (json.RawMessage, error) {
@@ -443,6 +467,10 @@ func (t *HTTP) decodeForm(r *http.Request, destination interface{}, o *httpDecod
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode HTTP Form Body because no validation schema was provided. This is a code bug."))
 	}
 
+	if o.fileSink != nil && r.Method != "GET" && httpx.HasContentType(r, httpContentTypeMultipartForm) {
+		return t.decodeMultipartForm(r, destination, o)
+	}
+
 	reader, err := t.requestBody(r, o)
 	if err != nil {
 		return err
@@ -453,7 +481,7 @@ func (t *HTTP) decodeForm(r *http.Request, destination interface{}, o *httpDecod
 	}()
 
 	if err := r.ParseForm(); err != nil {
-		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode HTTP %s form body: %s", strings.ToUpper(r.Method), err).WithDebug(err.Error()))
+		return readError(err, "Unable to decode HTTP %s form body: %s", strings.ToUpper(r.Method), err)
 	}
 
 	paths, err := jsonschemax.ListPathsWithRecursion(r.Context(), o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
@@ -475,7 +503,7 @@ func (t *HTTP) decodeForm(r *http.Request, destination interface{}, o *httpDecod
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err))
 	}
 
-	return t.validatePayload(r.Context(), raw, o)
+	return t.validatePayload(r.Context(), raw, o, true)
 }
 
 func (t *HTTP) decodeJSON(r *http.Request, destination interface{}, o *httpDecoderOptions) error {
@@ -486,7 +514,7 @@ func (t *HTTP) decodeJSON(r *http.Request, destination interface{}, o *httpDecod
 
 	raw, err := io.ReadAll(reader)
 	if err != nil {
-		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to read HTTP POST body: %s", err))
+		return readError(err, "Unable to read HTTP POST body: %s", err)
 	}
 
 	dc := json.NewDecoder(bytes.NewReader(raw))
@@ -494,7 +522,7 @@ func (t *HTTP) decodeJSON(r *http.Request, destination interface{}, o *httpDecod
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err).WithDebugf("Received request body: %s", string(raw)))
 	}
 
-	if err := t.validatePayload(r.Context(), raw, o); err != nil {
+	if err := t.validatePayload(r.Context(), raw, o, false); err != nil {
 		if o.expectJSONFlattened && strings.Contains(err.Error(), "json: unknown field") {
 			return t.decodeJSONForm(r, destination, o)
 		}
@@ -0,0 +1,368 @@
+package decoderx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+	"github.com/tidwall/sjson"
+
+	"github.com/huanggze/x/jsonschemax"
+)
+
+const (
+	// DefaultMaxFileSize is the default maximum size of a single uploaded file.
+	DefaultMaxFileSize int64 = 10 << 20
+	// DefaultMaxUploadSize is the default maximum size of all parts of a
+	// multipart request combined.
+	DefaultMaxUploadSize int64 = 32 << 20
+
+	// maxFormValueSize is the maximum size of a non-file form value.
+	maxFormValueSize int64 = 1 << 20
+)
+
+// ErrPayloadTooLarge is returned when a request body exceeds a size limit.
+var ErrPayloadTooLarge = herodot.DefaultError{
+	StatusField: http.StatusText(http.StatusRequestEntityTooLarge),
+	ErrorField:  "The request payload is too large",
+	CodeField:   http.StatusRequestEntityTooLarge,
+}
+
+var errSizeLimitExceeded = errors.New("size limit exceeded")
+
+type (
+	// UploadedFile is the handle of an uploaded file which is placed in the
+	// decoded payload instead of the file contents.
+	UploadedFile struct {
+		// Filename is the name of the file as sent by the client.
+		Filename string `json:"filename"`
+		// ContentType is the sniffed content type of the file.
+		ContentType string `json:"content_type"`
+		// Size is the size of the file in bytes.
+		Size int64 `json:"size"`
+		// Location is where the sink stored the file, e.g. a path.
+		Location string `json:"location"`
+	}
+
+	// FileSink stores uploaded files.
+	FileSink interface {
+		// StoreFile stores the contents of the file and returns its location.
+		// Size is not yet known when StoreFile is called.
+		StoreFile(ctx context.Context, file *UploadedFile, r io.Reader) (location string, err error)
+	}
+
+	// FileRemover is implemented by file sinks which can remove stored files.
+	// The decoder removes the files of a request which fails to decode.
+	FileRemover interface {
+		RemoveFile(ctx context.Context, location string) error
+	}
+
+	// FileSinkFunc is an adapter to use a callback as FileSink.
+	FileSinkFunc func(ctx context.Context, file *UploadedFile, r io.Reader) (location string, err error)
+
+	tempDirFileSink struct {
+		dir string
+	}
+
+	writerFileSink struct {
+		open func(ctx context.Context, file *UploadedFile) (w io.WriteCloser, location string, err error)
+	}
+
+	sizeLimitReader struct {
+		r io.Reader
+		n int64
+		// err is returned when the limit is exceeded. Defaults to
+		// errSizeLimitExceeded.
+		err error
+	}
+
+	countingReader struct {
+		r io.Reader
+		n int64
+	}
+)
+
+var (
+	_ FileSink    = FileSinkFunc(nil)
+	_ FileSink    = (*tempDirFileSink)(nil)
+	_ FileRemover = (*tempDirFileSink)(nil)
+	_ FileSink    = (*writerFileSink)(nil)
+)
+
+// StoreFile implements FileSink.
+func (f FileSinkFunc) StoreFile(ctx context.Context, file *UploadedFile, r io.Reader) (string, error) {
+	return f(ctx, file, r)
+}
+
+// TempDirFileSink stores uploaded files in the directory, or in the default
+// directory for temporary files if dir is empty. The location is the path of
+// the file.
+func TempDirFileSink(dir string) FileSink {
+	return &tempDirFileSink{dir: dir}
+}
+
+func (s *tempDirFileSink) StoreFile(_ context.Context, _ *UploadedFile, r io.Reader) (string, error) {
+	f, err := os.CreateTemp(s.dir, "upload-*")
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (s *tempDirFileSink) RemoveFile(_ context.Context, location string) error {
+	return errors.WithStack(os.Remove(location))
+}
+
+// WriterFileSink stores uploaded files in the writers returned by open, for
+// example files of an afero filesystem or objects of a blob store.
+func WriterFileSink(open func(ctx context.Context, file *UploadedFile) (w io.WriteCloser, location string, err error)) FileSink {
+	return &writerFileSink{open: open}
+}
+
+func (s *writerFileSink) StoreFile(ctx context.Context, file *UploadedFile, r io.Reader) (string, error) {
+	w, location, err := s.open(ctx, file)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return "", err
+	}
+	return location, errors.WithStack(w.Close())
+}
+
+// HTTPDecoderFileUploads enables multipart file uploads. File parts of fields
+// with a file format (see HTTPDecoderFileFormats) are streamed to the sink
+// and decoded as UploadedFile. The request body is not kept.
+func HTTPDecoderFileUploads(sink FileSink) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.fileSink = sink
+	}
+}
+
+// HTTPDecoderFileFormats sets the JSON Schema formats of fields which accept
+// file uploads. Defaults to "binary".
+func HTTPDecoderFileFormats(formats ...string) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.fileFormats = formats
+	}
+}
+
+// HTTPDecoderMaxFileSize sets the maximum size of a single uploaded file.
+// Defaults to DefaultMaxFileSize.
+func HTTPDecoderMaxFileSize(size int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxFileSize = size
+	}
+}
+
+// HTTPDecoderMaxUploadSize sets the maximum size of all parts of a multipart
+// request combined. Defaults to DefaultMaxUploadSize.
+func HTTPDecoderMaxUploadSize(size int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxUploadSize = size
+	}
+}
+
+// HTTPDecoderAllowedFileTypes restricts the sniffed content types of uploaded
+// files. Types may end with a wildcard, e.g. "image/*". All types are allowed
+// by default.
+func HTTPDecoderAllowedFileTypes(types ...string) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.allowedFileTypes = types
+	}
+}
+
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if r.n <= 0 {
+		// Probe whether there is more data than allowed.
+		if n, _ := r.r.Read(make([]byte, 1)); n > 0 {
+			if r.err != nil {
+				return 0, errors.WithStack(r.err)
+			}
+			return 0, errors.WithStack(errSizeLimitExceeded)
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.n {
+		p = p[:r.n]
+	}
+	n, err := r.r.Read(p)
+	r.n -= int64(n)
+	return n, err
+}
+
+func (o *httpDecoderOptions) isFilePath(path jsonschemax.Path) bool {
+	return slices.Contains(o.fileFormats, path.Format)
+}
+
+func (o *httpDecoderOptions) isAllowedFileType(contentType string) bool {
+	if len(o.allowedFileTypes) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return slices.ContainsFunc(o.allowedFileTypes, func(allowed string) bool {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			return strings.HasPrefix(mediaType, prefix)
+		}
+		return mediaType == allowed
+	})
+}
+
+func (t *HTTP) decodeMultipartForm(r *http.Request, destination interface{}, o *httpDecoderOptions) (err error) {
+	paths, err := jsonschemax.ListPathsWithRecursion(r.Context(), o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP Post Body Form parsing: %s", err).WithDebugf("%+v", err))
+	}
+
+	fileFields := make(map[string]jsonschemax.Path)
+	for _, path := range paths {
+		if o.isFilePath(path) {
+			fileFields[path.Name] = path
+		}
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode HTTP %s multipart form body: %s", strings.ToUpper(r.Method), err).WithDebug(err.Error()))
+	}
+
+	var stored []string
+	defer func() {
+		if remover, ok := o.fileSink.(FileRemover); ok && err != nil {
+			for _, location := range stored {
+				_ = remover.RemoveFile(r.Context(), location)
+			}
+		}
+	}()
+
+	values := url.Values{}
+	files := make(map[string][]*UploadedFile)
+	remaining := o.maxUploadSize
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return readError(err, "Unable to read multipart form part: %s", err)
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		if _, ok := fileFields[name]; !ok || part.FileName() == "" {
+			limit := min(maxFormValueSize, remaining)
+			value, err := io.ReadAll(&sizeLimitReader{r: part, n: limit})
+			remaining -= int64(len(value))
+			if errors.Is(err, errSizeLimitExceeded) {
+				return errors.WithStack(ErrPayloadTooLarge.WithReasonf("The form value %q exceeds the size limit of %d bytes.", name, limit))
+			} else if err != nil {
+				return readError(err, "Unable to read multipart form part: %s", err)
+			}
+			values.Add(name, string(value))
+			continue
+		}
+
+		file, err := t.storeFile(r.Context(), part, o, remaining)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, file.Location)
+		remaining -= file.Size
+		files[name] = append(files[name], file)
+	}
+
+	raw, err := t.decodeURLValues(values, paths, o)
+	if err != nil && !errors.Is(err, errKeyNotFound) {
+		return err
+	}
+
+	// The payload is validated with the file locations, which are strings
+	// like the values of "format: binary" fields, and decoded with handles.
+	validate, decode := raw, raw
+	for name, handles := range files {
+		var location, handle interface{} = handles[0].Location, handles[0]
+		if _, ok := fileFields[name].Type.([]string); ok {
+			locations := make([]string, len(handles))
+			for k, h := range handles {
+				locations[k] = h.Location
+			}
+			location, handle = locations, handles
+		}
+
+		if validate, err = sjson.SetBytes(validate, name, location); err != nil {
+			return errors.WithStack(err)
+		}
+		if decode, err = sjson.SetBytes(decode, name, handle); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if err := t.validatePayload(r.Context(), validate, o, true); err != nil {
+		return err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(decode)).Decode(destination); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err))
+	}
+	return nil
+}
+
+func (t *HTTP) storeFile(ctx context.Context, part *multipart.Part, o *httpDecoderOptions, remaining int64) (*UploadedFile, error) {
+	limit := min(o.maxFileSize, remaining)
+	body := &sizeLimitReader{r: part, n: limit}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if errors.Is(err, errSizeLimitExceeded) {
+		return nil, errors.WithStack(ErrPayloadTooLarge.WithReasonf("The file %q exceeds the size limit of %d bytes.", part.FileName(), limit))
+	} else if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, readError(err, "Unable to read multipart form part: %s", err)
+	}
+	head = head[:n]
+
+	file := &UploadedFile{
+		Filename:    part.FileName(),
+		ContentType: http.DetectContentType(head),
+	}
+	if !o.isAllowedFileType(file.ContentType) {
+		return nil, errors.WithStack(herodot.ErrUnsupportedMediaType.WithReasonf("Files of type %q are not allowed for field %q.", file.ContentType, part.FormName()))
+	}
+
+	counter := &countingReader{r: io.MultiReader(bytes.NewReader(head), body)}
+	location, err := o.fileSink.StoreFile(ctx, file, counter)
+	if errors.Is(err, errSizeLimitExceeded) {
+		return nil, errors.WithStack(ErrPayloadTooLarge.WithReasonf("The file %q exceeds the size limit of %d bytes.", part.FileName(), limit))
+	} else if errors.As(err, new(*bodyLimitError)) {
+		return nil, readError(err, "")
+	} else if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to store the uploaded file %q.", part.FileName()).WithTrace(err).WithDebug(err.Error()))
+	}
+
+	file.Location, file.Size = location, counter.n
+	return file, nil
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,184 @@
+package decoderx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/httpx"
+	"github.com/huanggze/x/jsonschemax"
+	"github.com/huanggze/x/jsonx"
+)
+
+const (
+	httpContentTypeYAML       = "application/yaml"
+	httpContentTypeXYAML      = "application/x-yaml"
+	httpContentTypeTextYAML   = "text/yaml"
+	httpContentTypeMergePatch = "application/merge-patch+json"
+	httpContentTypeJSONPatch  = "application/json-patch+json"
+)
+
+var yamlContentTypes = []string{httpContentTypeYAML, httpContentTypeXYAML, httpContentTypeTextYAML}
+
+// HTTPDecoderSetIgnoreParseErrorsStrategy sets how type conversion errors of
+// form values, query parameters, and headers are handled. Defaults to
+// ParseErrorIgnoreConversionErrors.
+func HTTPDecoderSetIgnoreParseErrorsStrategy(strategy parseErrorStrategy) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.handleParseErrors = strategy
+	}
+}
+
+// HTTPDecoderUseQueryAndBody merges the query parameters into form bodies.
+func HTTPDecoderUseQueryAndBody() HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.queryAndBody = true
+	}
+}
+
+// HTTPDecoderYAML accepts YAML request bodies.
+func HTTPDecoderYAML() HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.allowedContentTypes = append(o.allowedContentTypes, yamlContentTypes...)
+	}
+}
+
+// HTTPDecoderPatch accepts JSON Merge Patch (application/merge-patch+json)
+// and JSON Patch (application/json-patch+json) request bodies, which are
+// applied to the original document. The patched document is validated and
+// decoded.
+func HTTPDecoderPatch(original json.RawMessage) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.allowedContentTypes = append(o.allowedContentTypes, httpContentTypeMergePatch, httpContentTypeJSONPatch)
+		o.patchTarget = original
+	}
+}
+
+// DecodeQuery decodes the query parameters of the request into destination
+// according to the JSON Schema. It works for requests of all methods.
+func (t *HTTP) DecodeQuery(r *http.Request, destination interface{}, opts ...HTTPDecoderOption) error {
+	o := newHTTPDecoderOptions(opts)
+	return t.decodeValues(r, r.URL.Query(), destination, o)
+}
+
+// DecodeHeaders decodes the request headers into destination according to
+// the JSON Schema. Top-level properties of the schema are matched against the
+// header names case-insensitively, e.g. property "x-request-id" is read from
+// header "X-Request-Id". Headers not in the schema are ignored.
+func (t *HTTP) DecodeHeaders(r *http.Request, destination interface{}, opts ...HTTPDecoderOption) error {
+	o := newHTTPDecoderOptions(opts)
+	if o.jsonSchemaCompiler == nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode HTTP headers because no validation schema was provided. This is a code bug."))
+	}
+
+	paths, err := jsonschemax.ListPathsWithRecursion(r.Context(), o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP header parsing: %s", err).WithDebugf("%+v", err))
+	}
+
+	values := url.Values{}
+	for _, path := range paths {
+		if strings.Contains(path.Name, ".") {
+			continue
+		}
+		if v := r.Header.Values(path.Name); len(v) > 0 {
+			values[path.Name] = v
+		}
+	}
+
+	return t.decodeValuesWithPaths(r, values, paths, destination, o)
+}
+
+func (t *HTTP) decodeValues(r *http.Request, values url.Values, destination interface{}, o *httpDecoderOptions) error {
+	if o.jsonSchemaCompiler == nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode HTTP query because no validation schema was provided. This is a code bug."))
+	}
+
+	paths, err := jsonschemax.ListPathsWithRecursion(r.Context(), o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP query parsing: %s", err).WithDebugf("%+v", err))
+	}
+
+	return t.decodeValuesWithPaths(r, values, paths, destination, o)
+}
+
+func (t *HTTP) decodeValuesWithPaths(r *http.Request, values url.Values, paths []jsonschemax.Path, destination interface{}, o *httpDecoderOptions) error {
+	raw, err := t.decodeURLValues(values, paths, o)
+	if err != nil && !errors.Is(err, errKeyNotFound) {
+		return err
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(destination); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err))
+	}
+
+	return t.validatePayload(r.Context(), raw, o, true)
+}
+
+func (t *HTTP) decodeYAML(r *http.Request, destination interface{}, o *httpDecoderOptions) error {
+	body, err := t.readBody(r, o)
+	if err != nil {
+		return err
+	}
+
+	raw, err := yaml.YAMLToJSON(body)
+	if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode YAML payload: %s", err))
+	}
+
+	return t.decodeRawJSON(r, raw, destination, o)
+}
+
+func (t *HTTP) decodePatch(r *http.Request, destination interface{}, o *httpDecoderOptions) error {
+	body, err := t.readBody(r, o)
+	if err != nil {
+		return err
+	}
+
+	original := o.patchTarget
+	if len(original) == 0 {
+		original = json.RawMessage(`{}`)
+	}
+
+	var raw []byte
+	if httpx.HasContentType(r, httpContentTypeJSONPatch) {
+		raw, err = jsonx.ApplyJSONPatch(original, body)
+	} else {
+		raw, err = jsonx.ApplyMergePatch(original, body)
+	}
+	if errors.Is(err, jsonx.ErrPatchTestFailed) {
+		return errors.WithStack(herodot.ErrConflict.WithReasonf("Unable to apply patch: %s", err))
+	} else if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to apply patch: %s", err))
+	}
+
+	return t.decodeRawJSON(r, raw, destination, o)
+}
+
+func (t *HTTP) readBody(r *http.Request, o *httpDecoderOptions) ([]byte, error) {
+	reader, err := t.requestBody(r, o)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, readError(err, "Unable to read HTTP %s body: %s", strings.ToUpper(r.Method), err)
+	}
+	return body, nil
+}
+
+func (t *HTTP) decodeRawJSON(r *http.Request, raw []byte, destination interface{}, o *httpDecoderOptions) error {
+	if err := json.NewDecoder(bytes.NewReader(raw)).Decode(destination); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err).WithDebugf("Received request body: %s", string(raw)))
+	}
+
+	return t.validatePayload(r.Context(), raw, o, false)
+}
@@ -4,38 +4,59 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
-	"encoding/base64"
 	stderrors "errors"
 	"io"
+	"maps"
 	"net/http"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/dgraph-io/ristretto/v2"
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
 
 	"github.com/huanggze/x/httpx"
-	"github.com/huanggze/x/stringsx"
 )
 
-// Fetcher is able to load file contents from http, https, file, and base64 locations.
+// Fetcher is able to load file contents from http, https, file, base64, and
+// data locations. Additional schemes can be registered using WithSchemeHandler.
 type Fetcher struct {
-	hc    *retryablehttp.Client
-	limit int64
-	cache *ristretto.Cache[[]byte, []byte]
-	ttl   time.Duration
+	hc         *retryablehttp.Client
+	limit      int64
+	cache      *ristretto.Cache[[]byte, []byte]
+	ttl        time.Duration
+	handlers   map[string]SchemeHandler
+	allowed    []string
+	sigKey     jwk.Key
+	sigAlg     jwa.SignatureAlgorithm
+	sigLocator SignatureLocator
+	onResponse func(*http.Response)
 }
 
 type opts struct {
-	hc    *retryablehttp.Client
-	limit int64
-	cache *ristretto.Cache[[]byte, []byte]
-	ttl   time.Duration
+	hc         *retryablehttp.Client
+	limit      int64
+	cache      *ristretto.Cache[[]byte, []byte]
+	ttl        time.Duration
+	handlers   map[string]SchemeHandler
+	allowed    []string
+	sigKey     jwk.Key
+	sigAlg     jwa.SignatureAlgorithm
+	sigLocator SignatureLocator
+	onResponse func(*http.Response)
 }
 
-var ErrUnknownScheme = stderrors.New("unknown scheme")
+var (
+	ErrUnknownScheme    = stderrors.New("unknown scheme")
+	ErrSchemeNotAllowed = stderrors.New("scheme not allowed")
+
+	// ErrIntegrityMismatch is matched by IntegrityError.
+	ErrIntegrityMismatch = stderrors.New("integrity mismatch")
+)
 
 // WithClient sets the http.Client the fetcher uses.
 func WithClient(hc *retryablehttp.Client) Modifier {
@@ -52,6 +73,14 @@ func WithMaxHTTPMaxBytes(limit int64) Modifier {
 	}
 }
 
+// WithResponseHook calls hook with every successful HTTP response, e.g. to
+// inspect caching headers. The body must not be read.
+func WithResponseHook(hook func(*http.Response)) Modifier {
+	return func(o *opts) {
+		o.onResponse = hook
+	}
+}
+
 func WithCache(cache *ristretto.Cache[[]byte, []byte], ttl time.Duration) Modifier {
 	return func(o *opts) {
 		if ttl < 0 {
@@ -62,9 +91,30 @@ func WithCache(cache *ristretto.Cache[[]byte, []byte], ttl time.Duration) Modifi
 	}
 }
 
+// WithSchemeHandler registers a handler for the given scheme (e.g. "embed"),
+// replacing any built-in handler for that scheme.
+func WithSchemeHandler(scheme string, h SchemeHandler) Modifier {
+	return func(o *opts) {
+		o.handlers[strings.ToLower(scheme)] = h
+	}
+}
+
+// WithAllowedSchemes restricts the fetcher to the given schemes. Fetching a
+// source with any other scheme fails with ErrSchemeNotAllowed. Use this when
+// fetching user-supplied locations, e.g. to prevent reading local files.
+func WithAllowedSchemes(schemes ...string) Modifier {
+	return func(o *opts) {
+		o.allowed = make([]string, len(schemes))
+		for i, s := range schemes {
+			o.allowed[i] = strings.ToLower(s)
+		}
+	}
+}
+
 func newOpts() *opts {
 	return &opts{
-		hc: httpx.NewResilientClient(),
+		hc:       httpx.NewResilientClient(),
+		handlers: make(map[string]SchemeHandler),
 	}
 }
 
@@ -76,7 +126,16 @@ func NewFetcher(opts ...Modifier) *Fetcher {
 	for _, f := range opts {
 		f(o)
 	}
-	return &Fetcher{hc: o.hc, limit: o.limit, cache: o.cache, ttl: o.ttl}
+	f := &Fetcher{hc: o.hc, limit: o.limit, cache: o.cache, ttl: o.ttl, allowed: o.allowed, sigKey: o.sigKey, sigAlg: o.sigAlg, sigLocator: o.sigLocator, onResponse: o.onResponse}
+	f.handlers = map[string]SchemeHandler{
+		"http":   f.fetchRemote,
+		"https":  f.fetchRemote,
+		"file":   f.fetchFile,
+		"base64": base64Handler,
+		"data":   DataURLHandler,
+	}
+	maps.Copy(f.handlers, o.handlers)
+	return f
 }
 
 // Fetch fetches the file contents from the source.
@@ -96,21 +155,42 @@ func (f *Fetcher) FetchContext(ctx context.Context, source string) (*bytes.Buffe
 
 // FetchBytes fetches the file contents from the source and allows to pass a
 // context that is used for HTTP requests.
+//
+// The expected digest may be appended to the source as a fragment, e.g.
+// "https://example.com/schema.json#integrity=sha256-...", in which case the
+// contents are verified like in FetchBytesWithIntegrity.
 func (f *Fetcher) FetchBytes(ctx context.Context, source string) ([]byte, error) {
-	switch s := stringsx.SwitchPrefix(source); {
-	case s.HasPrefix("http://", "https://"):
-		return f.fetchRemote(ctx, source)
-	case s.HasPrefix("file://"):
-		return f.fetchFile(strings.TrimPrefix(source, "file://"))
-	case s.HasPrefix("base64://"):
-		src, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(source, "base64://"))
-		if err != nil {
-			return nil, errors.Wrapf(err, "base64decode: %s", source)
-		}
-		return src, nil
-	default:
-		return nil, errors.Wrap(ErrUnknownScheme, s.ToUnknownPrefixErr().Error())
+	source, integrity := splitIntegrity(source)
+	return f.FetchBytesWithIntegrity(ctx, source, integrity)
+}
+
+func (f *Fetcher) fetch(ctx context.Context, source string) ([]byte, error) {
+	scheme, ok := schemeOf(source)
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownScheme, "expected %s to have one of the schemes %v", source, f.schemes())
+	}
+	if len(f.allowed) > 0 && !slices.Contains(f.allowed, scheme) {
+		return nil, errors.Wrapf(ErrSchemeNotAllowed, "expected %s to have one of the schemes %v", source, f.allowed)
 	}
+	h, ok := f.handlers[scheme]
+	if !ok {
+		return nil, errors.Wrapf(ErrUnknownScheme, "expected %s to have one of the schemes %v", source, f.schemes())
+	}
+	return h(ctx, source)
+}
+
+// schemes returns the sorted list of registered schemes.
+func (f *Fetcher) schemes() []string {
+	return slices.Sorted(maps.Keys(f.handlers))
+}
+
+// evict removes the source from the cache, e.g. because it failed verification.
+func (f *Fetcher) evict(source string) {
+	if f.cache == nil {
+		return
+	}
+	cacheKey := sha256.Sum256([]byte(source))
+	f.cache.Del(cacheKey[:])
 }
 
 func (f *Fetcher) fetchRemote(ctx context.Context, source string) (b []byte, err error) {
@@ -143,6 +223,9 @@ func (f *Fetcher) fetchRemote(ctx context.Context, source string) (b []byte, err
 	if res.StatusCode != http.StatusOK {
 		return nil, errors.Errorf("expected http response status code 200 but got %d when fetching: %s", res.StatusCode, source)
 	}
+	if f.onResponse != nil {
+		f.onResponse(res)
+	}
 
 	if f.limit > 0 {
 		var buf bytes.Buffer
@@ -158,7 +241,8 @@ func (f *Fetcher) fetchRemote(ctx context.Context, source string) (b []byte, err
 	return io.ReadAll(res.Body)
 }
 
-func (f *Fetcher) fetchFile(source string) ([]byte, error) {
+func (f *Fetcher) fetchFile(_ context.Context, source string) ([]byte, error) {
+	source = strings.TrimPrefix(source, "file://")
 	fp, err := os.Open(source) // #nosec:G304
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to open file: %s", source)
@@ -0,0 +1,217 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/pkg/errors"
+)
+
+// integrityFragment is the URL fragment prefix used to pass the expected
+// digest as part of the source, e.g.
+// "https://example.com/schema.json#integrity=sha256-...".
+const integrityFragment = "#integrity="
+
+// SignatureLocator returns the location of the detached signature for the
+// given source.
+type SignatureLocator func(source string) string
+
+// IntegrityError is returned when fetched content does not match the expected
+// digest or its detached signature is invalid.
+type IntegrityError struct {
+	// Source is the location that was fetched.
+	Source string
+	// Expected is the expected integrity metadata. Empty if the signature
+	// verification failed.
+	Expected string
+	// Actual is the integrity of the fetched content, computed with the
+	// strongest algorithm of the expected integrity metadata, or sha256.
+	Actual string
+	// Signature is the reason the detached signature verification failed, if any.
+	Signature error
+}
+
+var _ error = (*IntegrityError)(nil)
+
+func (e *IntegrityError) Error() string {
+	if e.Signature != nil {
+		return fmt.Sprintf("%s for %s: detached signature is invalid: %s", ErrIntegrityMismatch, e.Source, e.Signature)
+	}
+	return fmt.Sprintf("%s for %s: expected %s but got %s", ErrIntegrityMismatch, e.Source, e.Expected, e.Actual)
+}
+
+func (e *IntegrityError) Is(err error) bool {
+	return err == ErrIntegrityMismatch
+}
+
+func (e *IntegrityError) Unwrap() error {
+	return e.Signature
+}
+
+// WithDetachedSignature verifies every fetched document against a detached
+// JWS (RFC 7515, Appendix F) signed by key with the algorithm alg. If alg is
+// empty, the "alg" of the key is used, and verification fails if the key has
+// none, as the algorithm is never taken from the untrusted JWS header. The
+// signature is loaded from the location returned by locate, or from the source
+// with ".sig" appended to its path if locate is nil.
+func WithDetachedSignature(key jwk.Key, alg jwa.SignatureAlgorithm, locate SignatureLocator) Modifier {
+	return func(o *opts) {
+		if locate == nil {
+			locate = DefaultSignatureLocation
+		}
+		o.sigKey = key
+		o.sigAlg = alg
+		o.sigLocator = locate
+	}
+}
+
+// DefaultSignatureLocation appends ".sig" to source, before the query if any.
+func DefaultSignatureLocation(source string) string {
+	if i := strings.IndexAny(source, "?#"); i >= 0 {
+		return source[:i] + ".sig" + source[i:]
+	}
+	return source + ".sig"
+}
+
+// Integrity returns the Subresource Integrity metadata (sha256) of b.
+func Integrity(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// actualIntegrity returns the integrity metadata of b computed with the
+// strongest algorithm of the expected integrity metadata, so that both can be
+// compared in error messages.
+func actualIntegrity(integrity string, b []byte) string {
+	tokens := strings.Fields(integrity)
+	for _, h := range integrityHashes {
+		for _, token := range tokens {
+			if strings.HasPrefix(token, h.prefix) {
+				hh := h.hash()
+				_, _ = hh.Write(b)
+				return h.prefix + base64.StdEncoding.EncodeToString(hh.Sum(nil))
+			}
+		}
+	}
+	return Integrity(b)
+}
+
+// FetchBytesWithIntegrity fetches the file contents from the source and
+// verifies them against the Subresource Integrity metadata, e.g.
+// "sha384-oqVuAfXRKap7fdgcCY5uykM6+R9GqQ8K/uxy9rx7HNQlGYl1kPzQho1wx4JwY8wC".
+// Multiple space-separated digests are allowed, in which case the content must
+// match one of the digests of the strongest algorithm.
+func (f *Fetcher) FetchBytesWithIntegrity(ctx context.Context, source, integrity string) ([]byte, error) {
+	b, err := f.fetch(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.verify(ctx, source, integrity, b); err != nil {
+		f.evict(source)
+		return nil, err
+	}
+	return b, nil
+}
+
+// splitIntegrity splits the integrity fragment from the source.
+func splitIntegrity(source string) (string, string) {
+	if i := strings.LastIndex(source, integrityFragment); i >= 0 {
+		return source[:i], source[i+len(integrityFragment):]
+	}
+	return source, ""
+}
+
+func (f *Fetcher) verify(ctx context.Context, source, integrity string, b []byte) error {
+	if integrity != "" {
+		ok, err := matchIntegrity(integrity, b)
+		if err != nil {
+			return errors.Wrapf(err, "invalid integrity metadata for %s", source)
+		}
+		if !ok {
+			return errors.WithStack(&IntegrityError{Source: source, Expected: integrity, Actual: actualIntegrity(integrity, b)})
+		}
+	}
+
+	if f.sigKey != nil {
+		sig, err := f.fetch(ctx, f.sigLocator(source))
+		if err != nil {
+			return errors.Wrapf(err, "unable to fetch detached signature for %s", source)
+		}
+		if err := verifyDetached(sig, b, f.sigKey, f.sigAlg); err != nil {
+			f.evict(f.sigLocator(source))
+			return errors.WithStack(&IntegrityError{Source: source, Actual: Integrity(b), Signature: err})
+		}
+	}
+
+	return nil
+}
+
+var integrityHashes = []struct {
+	prefix string
+	hash   func() hash.Hash
+}{
+	// Ordered from strongest to weakest.
+	{prefix: "sha512-", hash: sha512.New},
+	{prefix: "sha384-", hash: sha512.New384},
+	{prefix: "sha256-", hash: sha256.New},
+}
+
+// matchIntegrity reports whether b matches any of the digests of the strongest
+// algorithm in the integrity metadata, as described in
+// https://www.w3.org/TR/SRI/#does-response-match-metadatalist.
+func matchIntegrity(integrity string, b []byte) (bool, error) {
+	tokens := strings.Fields(integrity)
+	for _, h := range integrityHashes {
+		var found bool
+		for _, token := range tokens {
+			digest, ok := strings.CutPrefix(token, h.prefix)
+			if !ok {
+				continue
+			}
+			found = true
+
+			// Options such as "?ct=application/json" are not used.
+			digest, _, _ = strings.Cut(digest, "?")
+			expected, err := base64.StdEncoding.DecodeString(digest)
+			if err != nil {
+				return false, errors.WithStack(err)
+			}
+
+			hh := h.hash()
+			_, _ = hh.Write(b)
+			if subtle.ConstantTimeCompare(expected, hh.Sum(nil)) == 1 {
+				return true, nil
+			}
+		}
+		if found {
+			return false, nil
+		}
+	}
+	return false, errors.Errorf("expected one of the algorithms sha256, sha384, sha512 in: %s", integrity)
+}
+
+func verifyDetached(sig, payload []byte, key jwk.Key, alg jwa.SignatureAlgorithm) error {
+	if alg == "" {
+		alg = jwa.SignatureAlgorithm(key.Algorithm())
+	}
+	if alg == "" {
+		return errors.New("the signature algorithm must be set on the key or passed to WithDetachedSignature")
+	}
+	if alg == jwa.NoSignature {
+		return errors.New("unsigned detached signatures are not allowed")
+	}
+
+	if _, err := jws.Verify(sig, alg, key, jws.WithDetachedPayload(payload)); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/base64"
+	"io/fs"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SchemeHandler loads the contents of a source. The source is passed
+// unmodified, including the scheme the handler was registered for.
+type SchemeHandler func(ctx context.Context, source string) ([]byte, error)
+
+// schemeOf returns the scheme of the source, e.g. "https" for
+// "https://example.com" and "data" for "data:,hello".
+func schemeOf(source string) (string, bool) {
+	scheme, _, ok := strings.Cut(source, ":")
+	if !ok || scheme == "" {
+		return "", false
+	}
+	return strings.ToLower(scheme), true
+}
+
+// FSHandler returns a SchemeHandler that resolves sources relative to the root
+// of fsys. The scheme and any leading slashes are stripped, so
+// "embed:///schemas/a.json" and "embed://schemas/a.json" both load
+// "schemas/a.json". Paths escaping the root (e.g. containing "..") are
+// rejected.
+func FSHandler(fsys fs.FS) SchemeHandler {
+	return func(_ context.Context, source string) ([]byte, error) {
+		_, name, ok := strings.Cut(source, ":")
+		if !ok {
+			return nil, errors.Errorf("unable to determine path of: %s", source)
+		}
+		name = strings.TrimLeft(name, "/")
+		if !fs.ValidPath(name) {
+			return nil, errors.Wrapf(fs.ErrInvalid, "path is not allowed: %s", source)
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read file: %s", source)
+		}
+		return b, nil
+	}
+}
+
+// DataURLHandler is a SchemeHandler for RFC 2397 data URLs, e.g.
+// "data:application/json;base64,e30=" or "data:,%7B%7D". The media type is
+// ignored.
+func DataURLHandler(_ context.Context, source string) ([]byte, error) {
+	_, rest, ok := strings.Cut(source, ":")
+	if !ok {
+		return nil, errors.Errorf("data url is missing the scheme: %s", source)
+	}
+	header, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return nil, errors.Errorf("data url is missing the comma separator: %s", source)
+	}
+
+	if strings.HasSuffix(strings.ToLower(header), ";base64") {
+		src, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, errors.Wrapf(err, "base64decode: %s", source)
+		}
+		return src, nil
+	}
+
+	src, err := url.PathUnescape(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unescape: %s", source)
+	}
+	return []byte(src), nil
+}
+
+// MemoryHandler returns a SchemeHandler that serves the given files. Keys are
+// full sources, e.g. "mem://schema.json". Useful for tests.
+func MemoryHandler(files map[string][]byte) SchemeHandler {
+	return func(_ context.Context, source string) ([]byte, error) {
+		b, ok := files[source]
+		if !ok {
+			return nil, errors.Wrapf(fs.ErrNotExist, "unable to find file: %s", source)
+		}
+		out := make([]byte, len(b))
+		copy(out, b)
+		return out, nil
+	}
+}
+
+func base64Handler(_ context.Context, source string) ([]byte, error) {
+	src, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(source, "base64://"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "base64decode: %s", source)
+	}
+	return src, nil
+}
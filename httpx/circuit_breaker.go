@@ -0,0 +1,284 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/huanggze/x/logrusx"
+)
+
+const (
+	// CircuitClosed lets all requests pass.
+	CircuitClosed CircuitState = iota
+	// CircuitHalfOpen lets a limited number of probe requests pass.
+	CircuitHalfOpen
+	// CircuitOpen rejects all requests.
+	CircuitOpen
+)
+
+// ErrCircuitOpen is returned when a request is rejected by an open circuit breaker.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type (
+	// CircuitState is the state of a per-host circuit breaker.
+	CircuitState int
+
+	// CircuitBreakerConfig configures the per-host circuit breaker.
+	CircuitBreakerConfig struct {
+		// FailureThreshold is the number of consecutive failures after which
+		// the circuit opens. Defaults to 5.
+		FailureThreshold int
+		// OpenTimeout is how long the circuit stays open before probe requests
+		// are let through. Defaults to 30 seconds.
+		OpenTimeout time.Duration
+		// HalfOpenRequests is the number of successful probe requests required
+		// to close the circuit again. Defaults to 1.
+		HalfOpenRequests int
+	}
+
+	circuit struct {
+		state     CircuitState
+		failures  int
+		successes int
+		probes    int
+		openedAt  time.Time
+		// pending is the number of requests in flight and usedAt the time
+		// the last one started, which determine when the circuit is evicted.
+		pending int
+		usedAt  time.Time
+	}
+
+	circuitBreaker struct {
+		next     http.RoundTripper
+		c        CircuitBreakerConfig
+		l        interface{}
+		m        *circuitBreakerMetrics
+		mu       sync.Mutex
+		circuits map[string]*circuit
+		now      func() time.Time
+	}
+
+	circuitBreakerMetrics struct {
+		state       *prometheus.GaugeVec
+		transitions *prometheus.CounterVec
+		rejected    *prometheus.CounterVec
+	}
+)
+
+var _ http.RoundTripper = (*circuitBreaker)(nil)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitHalfOpen:
+		return "half-open"
+	case CircuitOpen:
+		return "open"
+	}
+	return "unknown"
+}
+
+var (
+	breakerMetrics     *circuitBreakerMetrics
+	breakerMetricsOnce sync.Once
+)
+
+func newCircuitBreakerMetrics() *circuitBreakerMetrics {
+	breakerMetricsOnce.Do(func() {
+		breakerMetrics = &circuitBreakerMetrics{
+			state: registerCollector(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "httpx_circuit_breaker_state",
+				Help: "state of the per-host circuit breaker (0 closed, 1 half-open, 2 open)",
+			}, []string{"host"})),
+			transitions: registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "httpx_circuit_breaker_transitions_total",
+				Help: "number of per-host circuit breaker state changes",
+			}, []string{"host", "from", "to"})),
+			rejected: registerCollector(prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "httpx_circuit_breaker_rejected_total",
+				Help: "number of requests rejected by an open circuit breaker",
+			}, []string{"host"})),
+		}
+	})
+	return breakerMetrics
+}
+
+// registerCollector registers the collector with the default registerer,
+// returning the existing collector if it was registered already.
+func registerCollector[C prometheus.Collector](c C) C {
+	if err := prometheus.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+	}
+	return c
+}
+
+// newCircuitBreaker returns a new circuit breaker which logs state changes to
+// l, which is a *logrusx.Logger or a logger supported by retryablehttp.
+func newCircuitBreaker(next http.RoundTripper, c CircuitBreakerConfig, l interface{}) *circuitBreaker {
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = 5
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = 30 * time.Second
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = 1
+	}
+	return &circuitBreaker{
+		next:     next,
+		c:        c,
+		l:        l,
+		m:        newCircuitBreakerMetrics(),
+		circuits: make(map[string]*circuit),
+		now:      time.Now,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (b *circuitBreaker) RoundTrip(r *http.Request) (*http.Response, error) {
+	host := r.URL.Host
+	if err := b.allow(host); err != nil {
+		return nil, err
+	}
+
+	next := b.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	res, err := next.RoundTrip(r)
+	if errors.Is(err, context.Canceled) && r.Context().Err() != nil {
+		// The client gave up, which says nothing about the health of the host.
+		b.release(host)
+		return res, err
+	}
+	// Egress policy violations say nothing about the health of the host either.
+	b.record(host, errors.Is(err, ErrEgressDenied) || (err == nil && res.StatusCode < http.StatusInternalServerError))
+	return res, err
+}
+
+func (b *circuitBreaker) allow(host string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.evictIdle(now)
+
+	c, ok := b.circuits[host]
+	if !ok {
+		c = new(circuit)
+		b.circuits[host] = c
+	}
+
+	switch c.state {
+	case CircuitOpen:
+		if now.Sub(c.openedAt) < b.c.OpenTimeout {
+			b.m.rejected.WithLabelValues(host).Inc()
+			return errors.Wrapf(ErrCircuitOpen, "host %s", host)
+		}
+		b.transition(host, c, CircuitHalfOpen)
+		fallthrough
+	case CircuitHalfOpen:
+		if c.probes >= b.c.HalfOpenRequests {
+			b.m.rejected.WithLabelValues(host).Inc()
+			return errors.Wrapf(ErrCircuitOpen, "host %s", host)
+		}
+		c.probes++
+	}
+	c.pending++
+	c.usedAt = now
+	return nil
+}
+
+// evictIdle deletes closed circuits without failures which have not been
+// used for longer than the open timeout, so that the circuits of hosts which
+// are no longer called do not accumulate. It inspects at most sweepBatch
+// circuits and must be called with the lock held.
+func (b *circuitBreaker) evictIdle(now time.Time) {
+	var n int
+	for host, c := range b.circuits {
+		if c.state == CircuitClosed && c.failures == 0 && c.pending == 0 && now.Sub(c.usedAt) > b.c.OpenTimeout {
+			delete(b.circuits, host)
+			b.m.state.DeleteLabelValues(host)
+		}
+		if n++; n >= sweepBatch {
+			return
+		}
+	}
+}
+
+func (b *circuitBreaker) record(host string, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[host]
+	c.pending--
+	switch c.state {
+	case CircuitClosed:
+		if success {
+			c.failures = 0
+			return
+		}
+		c.failures++
+		if c.failures >= b.c.FailureThreshold {
+			b.transition(host, c, CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		c.probes--
+		if !success {
+			b.transition(host, c, CircuitOpen)
+			return
+		}
+		c.successes++
+		if c.successes >= b.c.HalfOpenRequests {
+			b.transition(host, c, CircuitClosed)
+		}
+	}
+}
+
+// release frees the probe slot of a request which neither succeeded nor failed.
+func (b *circuitBreaker) release(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuits[host]
+	c.pending--
+	if c.state == CircuitHalfOpen {
+		c.probes--
+	}
+}
+
+// transition must be called with the lock held.
+func (b *circuitBreaker) transition(host string, c *circuit, to CircuitState) {
+	from := c.state
+	c.state, c.failures, c.successes, c.probes = to, 0, 0, 0
+	if to == CircuitOpen {
+		c.openedAt = b.now()
+	}
+
+	b.m.state.WithLabelValues(host).Set(float64(to))
+	b.m.transitions.WithLabelValues(host, from.String(), to.String()).Inc()
+	switch l := b.l.(type) {
+	case *logrusx.Logger:
+		l.WithField("host", host).
+			WithField("from", from.String()).
+			WithField("to", to.String()).
+			Warn("Circuit breaker changed state.")
+	case retryablehttp.LeveledLogger:
+		l.Warn("Circuit breaker changed state.", "host", host, "from", from.String(), "to", to.String())
+	case retryablehttp.Logger:
+		l.Printf("[WARN] Circuit breaker changed state for host %s from %s to %s.", host, from, to)
+	}
+}
@@ -27,6 +27,9 @@ func GetClientIPAddressesWithoutInternalIPs(ipAddresses []string) (string, error
 	return res, nil
 }
 
+// ClientIP returns the client IP from the forwarding headers, trusting them
+// regardless of who sent the request. Use ClientIPResolver to only honour
+// headers set by trusted proxies.
 func ClientIP(r *http.Request) string {
 	if trueClientIP := r.Header.Get("True-Client-IP"); trueClientIP != "" {
 		return trueClientIP
@@ -42,6 +45,9 @@ func ClientIP(r *http.Request) string {
 	}
 }
 
+// ClientGeoLocation returns the geo location from the Cloudflare headers,
+// trusting them regardless of who sent the request. Use
+// ClientIPResolver.ClientGeoLocation to only honour headers set by trusted proxies.
 func ClientGeoLocation(r *http.Request) *GeoLocation {
 	return &GeoLocation{
 		City:    r.Header.Get("Cf-Ipcity"),
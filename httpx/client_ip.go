@@ -0,0 +1,170 @@
+package httpx
+
+import (
+	"bytes"
+	_ "embed"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	HeaderTrueClientIP   = "True-Client-IP"
+	HeaderCFConnectingIP = "Cf-Connecting-IP"
+	HeaderXRealIP        = "X-Real-IP"
+	HeaderForwarded      = "Forwarded"
+	HeaderXForwardedFor  = "X-Forwarded-For"
+)
+
+// DefaultClientIPHeaders is the default precedence of forwarding headers.
+// Single-value headers set by CDNs and proxies, such as True-Client-IP,
+// Cf-Connecting-IP, and X-Real-IP, must be enabled explicitly, as clients can
+// set them unless the trusted proxy overwrites them.
+var DefaultClientIPHeaders = []string{
+	HeaderForwarded,
+	HeaderXForwardedFor,
+}
+
+//go:embed client_ip.schema.json
+var ClientIPConfigSchema string
+
+const ClientIPConfigSchemaID = "ory://client-ip-config"
+
+// AddClientIPConfigSchema adds the client IP schema to the compiler.
+// The interface is specified instead of `jsonschema.Compiler` to allow the use of any jsonschema library fork or version.
+func AddClientIPConfigSchema(c interface {
+	AddResource(url string, r io.Reader) error
+}) error {
+	return c.AddResource(ClientIPConfigSchemaID, bytes.NewBufferString(ClientIPConfigSchema))
+}
+
+// ClientIPConfig configures a ClientIPResolver.
+type ClientIPConfig struct {
+	// TrustedProxies are the CIDRs (or single IPs) of proxies whose forwarding
+	// headers are honoured.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// Headers is the precedence of forwarding headers. Defaults to
+	// DefaultClientIPHeaders.
+	Headers []string `json:"headers"`
+}
+
+// ClientIPResolver resolves the client IP of a request. Forwarding headers
+// are only honoured if the immediate peer (RemoteAddr) is a trusted proxy.
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+	headers []string
+}
+
+// NewClientIPResolver creates a new ClientIPResolver.
+func NewClientIPResolver(c *ClientIPConfig) (*ClientIPResolver, error) {
+	r := &ClientIPResolver{headers: DefaultClientIPHeaders}
+	if len(c.Headers) > 0 {
+		r.headers = make([]string, len(c.Headers))
+		for k, h := range c.Headers {
+			r.headers[k] = http.CanonicalHeaderKey(h)
+		}
+	}
+
+	trusted, err := parseCIDRs(c.TrustedProxies)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse trusted proxies")
+	}
+	r.trusted = trusted
+
+	return r, nil
+}
+
+// IsTrustedProxy reports whether the IP is within a trusted proxy CIDR.
+func (r *ClientIPResolver) IsTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	return slices.ContainsFunc(r.trusted, func(n *net.IPNet) bool {
+		return n.Contains(ip)
+	})
+}
+
+// ClientIP returns the IP of the client without the port. If the immediate
+// peer is a trusted proxy, the forwarding headers are inspected in the
+// configured order. For multi-hop headers (Forwarded, X-Forwarded-For) the
+// right-most address that is not a trusted proxy is used.
+func (r *ClientIPResolver) ClientIP(req *http.Request) string {
+	peer := remoteIP(req.RemoteAddr)
+	if !r.IsTrustedProxy(net.ParseIP(peer)) {
+		return peer
+	}
+
+	for _, h := range r.headers {
+		var hops []string
+		switch h {
+		case HeaderForwarded:
+			hops = parseForwardedFor(req.Header.Values(HeaderForwarded))
+		case HeaderXForwardedFor:
+			for _, v := range req.Header.Values(HeaderXForwardedFor) {
+				for _, hop := range strings.Split(v, ",") {
+					hops = append(hops, strings.TrimSpace(hop))
+				}
+			}
+		default:
+			if ip := net.ParseIP(strings.TrimSpace(req.Header.Get(h))); ip != nil {
+				return ip.String()
+			}
+			continue
+		}
+
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(hops[i])
+			if ip == nil {
+				// Obfuscated or unknown identifiers can not be trusted.
+				break
+			}
+			if i == 0 || !r.IsTrustedProxy(ip) {
+				return ip.String()
+			}
+		}
+	}
+
+	return peer
+}
+
+// ClientGeoLocation returns the geo location headers set by Cloudflare if the
+// immediate peer is a trusted proxy, and an empty location otherwise.
+func (r *ClientIPResolver) ClientGeoLocation(req *http.Request) *GeoLocation {
+	if !r.IsTrustedProxy(net.ParseIP(remoteIP(req.RemoteAddr))) {
+		return &GeoLocation{}
+	}
+	return ClientGeoLocation(req)
+}
+
+func remoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// parseForwardedFor returns the "for" parameters of RFC 7239 Forwarded
+// headers, in order, with ports and IPv6 brackets removed.
+func parseForwardedFor(values []string) (hops []string) {
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(key, "for") {
+					continue
+				}
+
+				value = strings.Trim(value, `"`)
+				if host, _, err := net.SplitHostPort(value); err == nil {
+					value = host
+				}
+				hops = append(hops, strings.Trim(value, "[]"))
+			}
+		}
+	}
+	return hops
+}
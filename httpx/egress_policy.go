@@ -0,0 +1,303 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrEgressDenied is matched by all errors returned for requests which
+// violate an EgressPolicy.
+var ErrEgressDenied = errors.New("outgoing request denied by egress policy")
+
+// DefaultMaxRedirects is the number of redirects followed if no limit is set.
+const DefaultMaxRedirects = 10
+
+// internalCIDRs are the loopback, private, link-local, shared, multicast, and
+// otherwise reserved networks which are denied unless explicitly allowed.
+var internalCIDRs = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"100::/64",
+	"2001:db8::/32",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+type (
+	// EgressPolicyConfig configures an EgressPolicy.
+	//
+	// An IP is denied if it is within DenyCIDRs, otherwise allowed if it is
+	// within AllowCIDRs, otherwise denied if it is an internal IP, and allowed
+	// otherwise.
+	EgressPolicyConfig struct {
+		// AllowCIDRs are CIDRs (or single IPs) which may be reached even if
+		// they are internal.
+		AllowCIDRs []string `json:"allow_cidrs"`
+		// DenyCIDRs are CIDRs (or single IPs) which may never be reached.
+		DenyCIDRs []string `json:"deny_cidrs"`
+		// AllowedPorts restricts the ports which may be reached. All ports are
+		// allowed if empty.
+		AllowedPorts []int `json:"allowed_ports"`
+		// AllowedSchemes restricts the URL schemes. Defaults to http and https.
+		AllowedSchemes []string `json:"allowed_schemes"`
+		// MaxRedirects is the number of redirects which are followed. Defaults
+		// to DefaultMaxRedirects, a negative value disables redirects.
+		MaxRedirects int `json:"max_redirects"`
+	}
+
+	// EgressPolicy decides which outgoing requests may be made. Use Transport
+	// and CheckRedirect, or ResilientClientWithEgressPolicy, to enforce it.
+	EgressPolicy struct {
+		allow, deny  []*net.IPNet
+		ports        []int
+		schemes      []string
+		maxRedirects int
+		resolver     *net.Resolver
+		dialer       *net.Dialer
+	}
+
+	// EgressPolicyError is returned for requests which violate an
+	// EgressPolicy. It carries HTTP status code 400 so that herodot writers
+	// respond with a client error when a user supplied URL is rejected.
+	EgressPolicyError struct {
+		URL       string
+		IP        net.IP
+		Violation string
+	}
+)
+
+// NewEgressPolicy creates a new EgressPolicy.
+func NewEgressPolicy(c *EgressPolicyConfig) (*EgressPolicy, error) {
+	p := &EgressPolicy{
+		ports:        c.AllowedPorts,
+		schemes:      []string{"http", "https"},
+		maxRedirects: c.MaxRedirects,
+		resolver:     net.DefaultResolver,
+		dialer:       &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+	if p.maxRedirects == 0 {
+		p.maxRedirects = DefaultMaxRedirects
+	}
+	if len(c.AllowedSchemes) > 0 {
+		p.schemes = make([]string, len(c.AllowedSchemes))
+		for k, s := range c.AllowedSchemes {
+			p.schemes[k] = strings.ToLower(s)
+		}
+	}
+
+	var err error
+	if p.allow, err = parseCIDRs(c.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if p.deny, err = parseCIDRs(c.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (e *EgressPolicyError) Error() string {
+	if e.IP != nil {
+		return fmt.Sprintf("%s: %s (%s): %s", ErrEgressDenied, e.URL, e.IP, e.Violation)
+	}
+	return fmt.Sprintf("%s: %s: %s", ErrEgressDenied, e.URL, e.Violation)
+}
+
+func (e *EgressPolicyError) Is(target error) bool {
+	return target == ErrEgressDenied
+}
+
+// StatusCode returns http.StatusBadRequest.
+func (e *EgressPolicyError) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Reason returns why the request was denied.
+func (e *EgressPolicyError) Reason() string {
+	return e.Violation
+}
+
+// CheckURL checks the scheme and port of the URL. The host is checked when
+// it is resolved at dial time.
+func (p *EgressPolicy) CheckURL(u *url.URL) error {
+	scheme := strings.ToLower(u.Scheme)
+	if !slices.Contains(p.schemes, scheme) {
+		return &EgressPolicyError{URL: u.Redacted(), Violation: fmt.Sprintf("scheme %q is not allowed", u.Scheme)}
+	}
+
+	port := u.Port()
+	if port == "" {
+		switch scheme {
+		case "http", "ws":
+			port = "80"
+		case "https", "wss":
+			port = "443"
+		}
+	}
+	if err := p.checkPort(port); err != nil {
+		return &EgressPolicyError{URL: u.Redacted(), Violation: err.Error()}
+	}
+	return nil
+}
+
+// CheckIP checks whether the IP may be reached.
+func (p *EgressPolicy) CheckIP(ip net.IP) error {
+	contains := func(n *net.IPNet) bool { return n.Contains(ip) }
+	switch {
+	case ip == nil:
+		return errors.New("address is not an IP")
+	case slices.ContainsFunc(p.deny, contains):
+		return errors.New("IP is within a denied network")
+	case slices.ContainsFunc(p.allow, contains):
+		return nil
+	case slices.ContainsFunc(internalCIDRs, contains) || ip.IsPrivate() || ip.IsLoopback() ||
+		ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast():
+		return errors.New("IP is internal")
+	}
+	return nil
+}
+
+func (p *EgressPolicy) checkPort(port string) error {
+	if len(p.ports) == 0 {
+		return nil
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || !slices.Contains(p.ports, n) {
+		return errors.Errorf("port %q is not allowed", port)
+	}
+	return nil
+}
+
+// DialContext resolves the address, checks all resolved IPs against the
+// policy, and dials the first allowed IP directly. Because the checked IP is
+// the one being dialed, the host can not be rebound to another IP between the
+// check and the connection.
+func (p *EgressPolicy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := p.checkPort(port); err != nil {
+		return nil, &EgressPolicyError{URL: addr, Violation: err.Error()}
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := p.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, a := range addrs {
+			ips = append(ips, a.IP)
+		}
+	}
+
+	var denied *EgressPolicyError
+	for _, ip := range ips {
+		if err := p.CheckIP(ip); err != nil {
+			if denied == nil {
+				denied = &EgressPolicyError{URL: addr, IP: ip, Violation: err.Error()}
+			}
+			continue
+		}
+		return p.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+	}
+	if denied == nil {
+		denied = &EgressPolicyError{URL: addr, Violation: "host did not resolve to any IP"}
+	}
+	return nil, denied
+}
+
+// CheckRedirect can be used as http.Client.CheckRedirect. It enforces the
+// maximum number of redirects and checks every hop against the policy.
+func (p *EgressPolicy) CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > p.maxRedirects || p.maxRedirects < 0 {
+		return &EgressPolicyError{URL: req.URL.Redacted(), Violation: fmt.Sprintf("stopped after %d redirects", len(via))}
+	}
+	return p.CheckURL(req.URL)
+}
+
+// Transport returns a transport which enforces the policy on every request
+// and pins the checked IP at dial time. Proxies are not used because they
+// would dial on behalf of the transport.
+func (p *EgressPolicy) Transport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = p.DialContext
+	t.DialTLSContext = nil
+	return &egressRoundTripper{p: p, next: t}
+}
+
+type egressRoundTripper struct {
+	p    *EgressPolicy
+	next http.RoundTripper
+}
+
+var _ http.RoundTripper = (*egressRoundTripper)(nil)
+
+// RoundTrip implements http.RoundTripper.
+func (t *egressRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	if err := t.p.CheckURL(r.URL); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(r)
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, errors.Errorf("neither an IP nor a CIDR: %s", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse CIDR: %s", cidr)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
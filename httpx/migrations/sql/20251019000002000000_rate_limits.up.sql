@@ -0,0 +1,11 @@
+CREATE TABLE rate_limits (
+  id VARCHAR(255) NOT NULL,
+  tokens DOUBLE PRECISION NOT NULL,
+  previous_count BIGINT NOT NULL,
+  current_count BIGINT NOT NULL,
+  reference_at TIMESTAMP(6) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX rate_limits_expires_at_idx ON rate_limits (expires_at);
@@ -0,0 +1,177 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTokenRequestFailed is returned when no access token could be obtained.
+var ErrTokenRequestFailed = errors.New("unable to obtain an OAuth2 access token")
+
+// tokenExpiryDelta is how long before its expiry a cached token is refreshed.
+const tokenExpiryDelta = 10 * time.Second
+
+type (
+	// OAuth2ClientCredentialsConfig configures OAuth2ClientCredentials.
+	OAuth2ClientCredentialsConfig struct {
+		TokenURL     string
+		ClientID     string
+		ClientSecret string
+		Scopes       []string
+		// EndpointParams are additional parameters of the token request, for
+		// example "audience".
+		EndpointParams url.Values
+		// Client is used for token requests. Defaults to a client with a ten
+		// second timeout.
+		Client *http.Client
+	}
+
+	oauth2TokenSource struct {
+		c      OAuth2ClientCredentialsConfig
+		now    func() time.Time
+		mu     sync.Mutex
+		token  string
+		expiry time.Time
+	}
+
+	oauth2TokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+)
+
+// OAuth2ClientCredentials authenticates outgoing requests with an access
+// token obtained through the OAuth2 client credentials grant. The token is
+// cached until shortly before it expires. If the server responds with 401,
+// the token is dropped from the cache and the request is sent once more with
+// a new token if its body can be replayed.
+func OAuth2ClientCredentials(c OAuth2ClientCredentialsConfig) TransportMiddleware {
+	if c.Client == nil {
+		c.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	ts := &oauth2TokenSource{c: c, now: time.Now}
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			token, err := ts.Token(r.Context())
+			if err != nil {
+				return nil, err
+			}
+
+			authorized := r.Clone(r.Context())
+			authorized.Header.Set("Authorization", "Bearer "+token)
+			res, err := next.RoundTrip(authorized)
+			if err != nil || res.StatusCode != http.StatusUnauthorized {
+				return res, err
+			}
+
+			ts.invalidate(token)
+			if replayable := r.Body == nil || r.Body == http.NoBody || r.GetBody != nil; !replayable {
+				return res, nil
+			}
+
+			token, err = ts.Token(r.Context())
+			if err != nil {
+				// Return the original 401 response; the caller can retry later.
+				return res, nil
+			}
+			_ = res.Body.Close()
+
+			authorized = r.Clone(r.Context())
+			if r.GetBody != nil {
+				if authorized.Body, err = r.GetBody(); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+			authorized.Header.Set("Authorization", "Bearer "+token)
+			return next.RoundTrip(authorized)
+		})
+	}
+}
+
+// Token returns the cached access token or requests a new one.
+func (s *oauth2TokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || s.now().Add(tokenExpiryDelta).Before(s.expiry)) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.requestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.expiry = token, time.Time{}
+	if expiresIn > 0 {
+		s.expiry = s.now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return s.token, nil
+}
+
+// invalidate drops the token from the cache unless it was refreshed already.
+func (s *oauth2TokenSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+func (s *oauth2TokenSource) requestToken(ctx context.Context) (string, int64, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.c.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.c.Scopes, " "))
+	}
+	for k, v := range s.c.EndpointParams {
+		form[k] = v
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.c.ClientID), url.QueryEscape(s.c.ClientSecret))
+
+	res, err := s.c.Client.Do(req)
+	if err != nil {
+		return "", 0, errors.Wrap(ErrTokenRequestFailed, err.Error())
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return "", 0, errors.Wrap(ErrTokenRequestFailed, err.Error())
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", 0, errors.Wrap(ErrTokenRequestFailed, fmt.Sprintf("token endpoint responded with status code %d: %s", res.StatusCode, body))
+	}
+
+	var tr oauth2TokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", 0, errors.Wrap(ErrTokenRequestFailed, err.Error())
+	}
+	if tr.AccessToken == "" {
+		return "", 0, errors.Wrap(ErrTokenRequestFailed, "token endpoint response does not contain an access token")
+	}
+	if tr.TokenType != "" && !strings.EqualFold(tr.TokenType, "bearer") {
+		return "", 0, errors.Wrapf(ErrTokenRequestFailed, "unsupported token type %q", tr.TokenType)
+	}
+	return tr.AccessToken, tr.ExpiresIn, nil
+}
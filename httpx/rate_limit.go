@@ -0,0 +1,399 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+
+	"github.com/huanggze/x/logrusx"
+)
+
+const (
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
+	HeaderRetryAfter         = "Retry-After"
+)
+
+const (
+	// TokenBucket allows bursts of up to RateLimit.Burst requests and refills
+	// at a rate of RateLimit.Requests per RateLimit.Period.
+	TokenBucket RateLimitAlgorithm = iota
+	// SlidingWindow allows RateLimit.Requests per RateLimit.Period, weighting
+	// the requests of the previous window by how much it overlaps.
+	SlidingWindow
+)
+
+// ErrTooManyRequests is returned when a request is rate limited.
+var ErrTooManyRequests = herodot.DefaultError{
+	StatusField:   http.StatusText(http.StatusTooManyRequests),
+	ErrorField:    "Too many requests were made, please try again later",
+	CodeField:     http.StatusTooManyRequests,
+	GRPCCodeField: codes.ResourceExhausted,
+}
+
+type (
+	// RateLimitAlgorithm is the algorithm used to enforce a RateLimit.
+	RateLimitAlgorithm int
+
+	// RateLimit is the number of requests allowed per period.
+	RateLimit struct {
+		Requests  int
+		Period    time.Duration
+		Algorithm RateLimitAlgorithm
+		// Burst is the capacity of the token bucket. Defaults to Requests.
+		Burst int
+	}
+
+	// RateLimitResult is the outcome of a rate limit check.
+	RateLimitResult struct {
+		Allowed    bool
+		Limit      int
+		Remaining  int
+		Reset      time.Duration
+		RetryAfter time.Duration
+	}
+
+	// RateLimitState is the stored state of a single rate limit key.
+	RateLimitState struct {
+		ID string `db:"id"`
+		// Tokens left in the token bucket.
+		Tokens float64 `db:"tokens"`
+		// Previous and Current are the request counts of the sliding windows.
+		Previous int64 `db:"previous_count"`
+		Current  int64 `db:"current_count"`
+		// At is the time of the last refill or the start of the current window.
+		At        time.Time `db:"reference_at"`
+		ExpiresAt time.Time `db:"expires_at"`
+	}
+
+	// RateLimitStore stores rate limit states.
+	RateLimitStore interface {
+		// UpdateRateLimit loads the state of the key, which is zero if it does
+		// not exist, applies fn, and saves the result. The update must be
+		// atomic with respect to concurrent updates of the same key.
+		UpdateRateLimit(ctx context.Context, key string, fn func(*RateLimitState)) error
+	}
+
+	// RateLimitKeyFunc returns the key by which requests are limited. Requests
+	// for which an empty key is returned are not limited.
+	RateLimitKeyFunc func(r *http.Request) (string, error)
+
+	// MemoryRateLimitStore is an in-memory RateLimitStore for single instance
+	// deployments.
+	MemoryRateLimitStore struct {
+		mu     sync.Mutex
+		states map[string]*RateLimitState
+		now    func() time.Time
+	}
+
+	rateLimitRoute struct {
+		method string
+		path   glob.Glob
+		name   string
+		limit  RateLimit
+	}
+
+	// RateLimiter is a negroni middleware which rate limits requests.
+	RateLimiter struct {
+		limit  RateLimit
+		routes []rateLimitRoute
+		store  RateLimitStore
+		key    RateLimitKeyFunc
+		writer herodot.Writer
+		l      *logrusx.Logger
+		now    func() time.Time
+	}
+
+	// RateLimiterOption configures a RateLimiter.
+	RateLimiterOption func(*RateLimiter)
+)
+
+var _ RateLimitStore = (*MemoryRateLimitStore)(nil)
+
+// TableName returns the SQL table name.
+func (RateLimitState) TableName() string {
+	return "rate_limits"
+}
+
+// NewMemoryRateLimitStore creates a new MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{states: make(map[string]*RateLimitState), now: time.Now}
+}
+
+// UpdateRateLimit implements RateLimitStore.
+func (s *MemoryRateLimitStore) UpdateRateLimit(_ context.Context, key string, fn func(*RateLimitState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	sweepExpired(s.states, func(state *RateLimitState) bool { return now.After(state.ExpiresAt) })
+
+	state, ok := s.states[key]
+	if !ok {
+		state = &RateLimitState{ID: key}
+		s.states[key] = state
+	}
+	fn(state)
+	return nil
+}
+
+// RateLimitByClientIP limits requests by the client IP as resolved by the
+// resolver. If the resolver is nil, the remote address is used.
+func RateLimitByClientIP(resolver *ClientIPResolver) RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		if resolver == nil {
+			return remoteIP(r.RemoteAddr), nil
+		}
+		return resolver.ClientIP(r), nil
+	}
+}
+
+// RateLimitByHeader limits requests by the value of the header, for example
+// an API key. Requests without the header are not limited.
+func RateLimitByHeader(name string) RateLimitKeyFunc {
+	return func(r *http.Request) (string, error) {
+		return r.Header.Get(name), nil
+	}
+}
+
+// RateLimiterWithKeyFunc sets the function which derives the key from the
+// request. Defaults to RateLimitByClientIP without trusted proxies.
+func RateLimiterWithKeyFunc(f RateLimitKeyFunc) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.key = f
+	}
+}
+
+// RateLimiterWithStore sets the store. Defaults to a MemoryRateLimitStore.
+func RateLimiterWithStore(s RateLimitStore) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.store = s
+	}
+}
+
+// RateLimiterWithRoute applies a separate limit to requests matching the
+// method and the path glob, for example "/api/*/login". An empty method
+// matches all methods. The first matching route wins.
+func RateLimiterWithRoute(method, path string, limit RateLimit) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.routes = append(l.routes, rateLimitRoute{
+			method: strings.ToUpper(method),
+			path:   glob.MustCompile(path, '/'),
+			name:   method + " " + path,
+			limit:  limit,
+		})
+	}
+}
+
+// RateLimiterWithErrorWriter sets the writer used to respond to rate limited
+// requests. Defaults to a herodot JSON writer.
+func RateLimiterWithErrorWriter(w herodot.Writer) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.writer = w
+	}
+}
+
+// RateLimiterWithLogger sets the logger used to report store errors.
+func RateLimiterWithLogger(logger *logrusx.Logger) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.l = logger
+	}
+}
+
+// RateLimiterWithClock sets the clock. It is also used by a
+// MemoryRateLimitStore to expire states.
+func RateLimiterWithClock(now func() time.Time) RateLimiterOption {
+	return func(l *RateLimiter) {
+		l.now = now
+	}
+}
+
+// NewRateLimiter creates a new RateLimiter which applies the limit to all
+// requests not matching a route. It fails if any limit is invalid.
+func NewRateLimiter(limit RateLimit, opts ...RateLimiterOption) (*RateLimiter, error) {
+	l := &RateLimiter{
+		limit:  limit,
+		store:  NewMemoryRateLimitStore(),
+		key:    RateLimitByClientIP(nil),
+		writer: herodot.NewJSONWriter(nil),
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if s, ok := l.store.(*MemoryRateLimitStore); ok {
+		s.mu.Lock()
+		s.now = l.now
+		s.mu.Unlock()
+	}
+
+	if err := l.limit.validate(); err != nil {
+		return nil, err
+	}
+	for _, rt := range l.routes {
+		if err := rt.limit.validate(); err != nil {
+			return nil, errors.WithMessagef(err, "route %q", strings.TrimSpace(rt.name))
+		}
+	}
+	return l, nil
+}
+
+// Allow counts the request and reports whether it is within the limit.
+func (l *RateLimiter) Allow(r *http.Request) (*RateLimitResult, error) {
+	key, err := l.key(r)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return &RateLimitResult{Allowed: true}, nil
+	}
+
+	limit, route := l.limit, ""
+	for _, rt := range l.routes {
+		if (rt.method == "" || rt.method == r.Method) && rt.path.Match(r.URL.Path) {
+			limit, route = rt.limit, rt.name
+			break
+		}
+	}
+
+	var res RateLimitResult
+	now := l.now()
+	if err := l.store.UpdateRateLimit(r.Context(), rateLimitKey(route, key), func(state *RateLimitState) {
+		res = limit.take(state, now)
+	}); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// rateLimitKey derives the store key from the route and the request key. It
+// is hashed because the request key may be an arbitrarily long header value.
+func rateLimitKey(route, key string) string {
+	sum := sha256.Sum256([]byte(route + "|" + key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ServeHTTP implements negroni.Handler. Requests are let through if the
+// store fails, so that an outage of the store does not take down the service.
+func (l *RateLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	res, err := l.Allow(r)
+	if err != nil {
+		if l.l != nil {
+			l.l.WithRequest(r).WithError(err).Error("Unable to check the rate limit, letting the request pass.")
+		}
+		next(w, r)
+		return
+	}
+	if res.Limit == 0 {
+		next(w, r)
+		return
+	}
+
+	w.Header().Set(HeaderRateLimitLimit, strconv.Itoa(res.Limit))
+	w.Header().Set(HeaderRateLimitRemaining, strconv.Itoa(res.Remaining))
+	w.Header().Set(HeaderRateLimitReset, strconv.Itoa(ceilSeconds(res.Reset)))
+	if !res.Allowed {
+		retryAfter := max(ceilSeconds(res.RetryAfter), 1)
+		w.Header().Set(HeaderRetryAfter, strconv.Itoa(retryAfter))
+		l.writer.WriteError(w, r, errors.WithStack(ErrTooManyRequests.WithReasonf("The rate limit was exceeded, retry in %d seconds.", retryAfter)))
+		return
+	}
+	next(w, r)
+}
+
+func (limit RateLimit) validate() error {
+	switch {
+	case limit.Requests <= 0:
+		return errors.Errorf("the rate limit must allow a positive number of requests but got %d", limit.Requests)
+	case limit.Period <= 0:
+		return errors.Errorf("the rate limit period must be positive but got %s", limit.Period)
+	case limit.Burst < 0:
+		return errors.Errorf("the rate limit burst must not be negative but got %d", limit.Burst)
+	}
+	return nil
+}
+
+func (limit RateLimit) take(state *RateLimitState, now time.Time) RateLimitResult {
+	state.ExpiresAt = now.Add(2 * limit.Period)
+	if limit.Algorithm == SlidingWindow {
+		return limit.takeSlidingWindow(state, now)
+	}
+	return limit.takeTokenBucket(state, now)
+}
+
+func (limit RateLimit) takeTokenBucket(state *RateLimitState, now time.Time) RateLimitResult {
+	capacity := float64(limit.Requests)
+	if limit.Burst > 0 {
+		capacity = float64(limit.Burst)
+	}
+	rate := float64(limit.Requests) / limit.Period.Seconds()
+
+	if state.At.IsZero() {
+		state.Tokens = capacity
+	} else if elapsed := now.Sub(state.At).Seconds(); elapsed > 0 {
+		state.Tokens = math.Min(capacity, state.Tokens+elapsed*rate)
+	}
+	state.At = now
+
+	res := RateLimitResult{Limit: int(capacity)}
+	if state.Tokens >= 1 {
+		state.Tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - state.Tokens) / rate)
+	}
+	res.Remaining = int(state.Tokens)
+	res.Reset = seconds((capacity - state.Tokens) / rate)
+	return res
+}
+
+func (limit RateLimit) takeSlidingWindow(state *RateLimitState, now time.Time) RateLimitResult {
+	window := limit.Period
+	start := now.Truncate(window)
+	if !state.At.Equal(start) {
+		if state.At.Add(window).Equal(start) {
+			state.Previous = state.Current
+		} else {
+			state.Previous = 0
+		}
+		state.Current, state.At = 0, start
+	}
+
+	elapsed := now.Sub(start)
+	weight := 1 - float64(elapsed)/float64(window)
+	estimated := float64(state.Previous)*weight + float64(state.Current)
+
+	res := RateLimitResult{Limit: limit.Requests, Reset: window - elapsed}
+	if estimated+1 <= float64(limit.Requests) {
+		state.Current++
+		estimated++
+		res.Allowed = true
+	} else if free := float64(limit.Requests - 1 - int(state.Current)); free < 0 || state.Previous == 0 {
+		res.RetryAfter = window - elapsed
+	} else {
+		// Wait until the weight of the previous window dropped far enough.
+		res.RetryAfter = time.Duration((1-free/float64(state.Previous))*float64(window)) - elapsed
+	}
+	res.Remaining = max(limit.Requests-int(math.Ceil(estimated)), 0)
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"context"
+	"embed"
+	"time"
+
+	"github.com/ory/pop/v6"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/popx"
+	"github.com/huanggze/x/sqlcon"
+)
+
+// RateLimitMigrations of the SQL rate limit store. Apply by merging with your local migrations using
+// fsx.Merge() and then passing all to the migration box.
+//
+//go:embed migrations/sql/*.sql
+var RateLimitMigrations embed.FS
+
+var _ RateLimitStore = (*SQLRateLimitStore)(nil)
+
+// SQLRateLimitStore stores rate limit states in a SQL database, so that limits
+// are shared between instances.
+type SQLRateLimitStore struct {
+	c *pop.Connection
+}
+
+// NewSQLRateLimitStore returns a new SQLRateLimitStore.
+func NewSQLRateLimitStore(c *pop.Connection) *SQLRateLimitStore {
+	return &SQLRateLimitStore{c: c}
+}
+
+// UpdateRateLimit implements RateLimitStore.
+func (s *SQLRateLimitStore) UpdateRateLimit(ctx context.Context, key string, fn func(*RateLimitState)) error {
+	err := s.update(ctx, key, fn)
+	if errors.Is(err, sqlcon.ErrUniqueViolation) {
+		// Another instance created the state concurrently, so update it instead.
+		err = s.update(ctx, key, fn)
+	}
+	return err
+}
+
+func (s *SQLRateLimitStore) update(ctx context.Context, key string, fn func(*RateLimitState)) error {
+	return popx.Transaction(ctx, s.c, func(ctx context.Context, c *pop.Connection) error {
+		q := c.Where("id = ?", key)
+		if c.Dialect.Name() != "sqlite3" {
+			q = c.RawQuery("SELECT * FROM rate_limits WHERE id = ? FOR UPDATE", key)
+		}
+
+		var state RateLimitState
+		err := sqlcon.HandleError(q.First(&state))
+		if errors.Is(err, sqlcon.ErrNoRows) {
+			state = RateLimitState{ID: key}
+			fn(&state)
+			return sqlcon.HandleError(c.Create(&state))
+		} else if err != nil {
+			return err
+		}
+
+		fn(&state)
+		return sqlcon.HandleError(c.Update(&state))
+	})
+}
+
+// DeleteExpired deletes the states which expired before now.
+func (s *SQLRateLimitStore) DeleteExpired(ctx context.Context, now time.Time) error {
+	return sqlcon.HandleError(s.c.WithContext(ctx).RawQuery("DELETE FROM rate_limits WHERE expires_at < ?", now.UTC()).Exec())
+}
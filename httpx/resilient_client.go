@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"crypto/tls"
 	"io"
 	"log"
 	"net/http"
@@ -22,6 +23,11 @@ type resilientOptions struct {
 	internalIPExceptions []string
 	ipV6                 bool
 	tracer               trace.Tracer
+	breaker              *CircuitBreakerConfig
+	budget               *RetryBudgetConfig
+	egress               *EgressPolicy
+	middlewares          []TransportMiddleware
+	getClientCert        func(*tls.CertificateRequestInfo) (*tls.Certificate, error)
 }
 
 func newResilientOptions() *resilientOptions {
@@ -67,6 +73,30 @@ func ResilientClientWithLogger(l *logrusx.Logger) ResilientOptions {
 	}
 }
 
+// ResilientClientWithCircuitBreaker enables a per-host circuit breaker. Requests
+// to a host whose circuit is open fail with ErrCircuitOpen without being sent.
+func ResilientClientWithCircuitBreaker(c CircuitBreakerConfig) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.breaker = &c
+	}
+}
+
+// ResilientClientWithRetryBudget limits the retries of all requests made by the
+// client to a ratio of the requests made within a window.
+func ResilientClientWithRetryBudget(c RetryBudgetConfig) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.budget = &c
+	}
+}
+
+// ResilientClientWithEgressPolicy enforces the egress policy on every request
+// and redirect. It takes precedence over ResilientClientDisallowInternalIPs.
+func ResilientClientWithEgressPolicy(p *EgressPolicy) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.egress = p
+	}
+}
+
 // ResilientClientDisallowInternalIPs disallows internal IPs from being used.
 func ResilientClientDisallowInternalIPs() ResilientOptions {
 	return func(o *resilientOptions) {
@@ -89,7 +119,10 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 		f(o)
 	}
 
-	if o.noInternalIPs {
+	if o.egress != nil {
+		o.c.Transport = o.egress.Transport()
+		o.c.CheckRedirect = o.egress.CheckRedirect
+	} else if o.noInternalIPs {
 		o.c.Transport = &noInternalIPRoundTripper{
 			onWhitelist:          ifelse(o.ipV6, allowInternalAllowIPv6, allowInternalProhibitIPv6),
 			notOnWhitelist:       ifelse(o.ipV6, prohibitInternalAllowIPv6, prohibitInternalProhibitIPv6),
@@ -99,14 +132,36 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 		o.c.Transport = ifelse(o.ipV6, allowInternalAllowIPv6, allowInternalProhibitIPv6)
 	}
 
+	if o.getClientCert != nil {
+		o.c.Transport = withClientCertificate(o.c.Transport, o.getClientCert)
+	}
+
+	if o.breaker != nil {
+		o.c.Transport = newCircuitBreaker(o.c.Transport, *o.breaker, o.l)
+	}
+
+	o.c.Transport = wrapTransport(o.c.Transport, o.middlewares)
+
+	var budget *retryBudget
+	if o.budget != nil {
+		budget = newRetryBudget(*o.budget)
+	}
+
 	cl := retryablehttp.NewClient()
 	cl.HTTPClient = o.c
 	cl.Logger = o.l
 	cl.RetryWaitMin = o.retryWaitMin
 	cl.RetryWaitMax = o.retryWaitMax
 	cl.RetryMax = o.retryMax
-	cl.CheckRetry = retryablehttp.DefaultRetryPolicy
-	cl.Backoff = retryablehttp.DefaultBackoff
+	// Keep the retryablehttp defaults unless requests can be rejected without
+	// being sent, which must not be retried, or retries are budgeted.
+	if o.breaker != nil || o.egress != nil || budget != nil {
+		cl.CheckRetry = retryPolicy(budget, o.retryWaitMax)
+		cl.Backoff = backoff
+	}
+	if budget != nil {
+		cl.RequestLogHook = budget.requestLogHook
+	}
 
 	return cl
 }
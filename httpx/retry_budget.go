@@ -0,0 +1,161 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrRetryBudgetExhausted is returned when a request is not retried because
+// the retry budget is exhausted.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+type (
+	// RetryBudgetConfig configures the retry budget shared by all requests of
+	// a resilient client.
+	RetryBudgetConfig struct {
+		// Ratio is the maximum ratio of retries to requests, e.g. 0.2 to allow
+		// one retry for every five requests.
+		Ratio float64
+		// MinRetries is the number of retries allowed per window regardless of
+		// the ratio, so that clients with little traffic can still retry.
+		// Defaults to 10.
+		MinRetries int
+		// Window is the duration over which requests and retries are counted.
+		// Defaults to 10 seconds.
+		Window time.Duration
+	}
+
+	retryBudget struct {
+		c         RetryBudgetConfig
+		mu        sync.Mutex
+		start     time.Time
+		requests  int
+		retries   int
+		now       func() time.Time
+		exhausted prometheus.Counter
+	}
+)
+
+var (
+	retryBudgetExhausted     prometheus.Counter
+	retryBudgetExhaustedOnce sync.Once
+)
+
+func newRetryBudget(c RetryBudgetConfig) *retryBudget {
+	if c.MinRetries <= 0 {
+		c.MinRetries = 10
+	}
+	if c.Window <= 0 {
+		c.Window = 10 * time.Second
+	}
+	retryBudgetExhaustedOnce.Do(func() {
+		retryBudgetExhausted = registerCollector(prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "httpx_retry_budget_exhausted_total",
+			Help: "number of retries skipped because the retry budget was exhausted",
+		}))
+	})
+	return &retryBudget{c: c, now: time.Now, exhausted: retryBudgetExhausted}
+}
+
+// roll resets the counters if the window has passed. Must be called with the
+// lock held.
+func (b *retryBudget) roll() {
+	if now := b.now(); now.Sub(b.start) >= b.c.Window {
+		b.start, b.requests, b.retries = now, 0, 0
+	}
+}
+
+// requestLogHook counts requests and retries.
+func (b *retryBudget) requestLogHook(_ retryablehttp.Logger, _ *http.Request, attempt int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll()
+	if attempt == 0 {
+		b.requests++
+	} else {
+		b.retries++
+	}
+}
+
+// allow reports whether another retry fits into the budget.
+func (b *retryBudget) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll()
+	if b.retries < b.c.MinRetries || float64(b.retries) < b.c.Ratio*float64(b.requests) {
+		return true
+	}
+	b.exhausted.Inc()
+	return false
+}
+
+// retryPolicy extends the retryablehttp.DefaultRetryPolicy. Requests are not
+// retried if the circuit is open, the egress policy is violated, the retry
+// budget is exhausted, or the server asks to retry later than maxWait.
+func retryPolicy(budget *retryBudget, maxWait time.Duration) retryablehttp.CheckRetry {
+	return func(ctx context.Context, res *http.Response, err error) (bool, error) {
+		if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrEgressDenied) {
+			return false, err
+		}
+
+		retry, checkErr := retryablehttp.DefaultRetryPolicy(ctx, res, err)
+		if !retry || checkErr != nil {
+			return retry, checkErr
+		}
+
+		if wait, ok := retryAfter(res); ok && wait > maxWait {
+			return false, nil
+		}
+		if budget != nil && !budget.allow() {
+			if err != nil {
+				return false, errors.Wrap(ErrRetryBudgetExhausted, err.Error())
+			}
+			return false, nil
+		}
+		return true, nil
+	}
+}
+
+// backoff extends the retryablehttp.DefaultBackoff by honouring Retry-After
+// headers in both the delay-seconds and the HTTP-date format on 429 and 503
+// responses, bounded by min and max.
+func backoff(min, max time.Duration, attempt int, res *http.Response) time.Duration {
+	if wait, ok := retryAfter(res); ok {
+		if wait < min {
+			return min
+		}
+		if wait > max {
+			return max
+		}
+		return wait
+	}
+	return retryablehttp.DefaultBackoff(min, max, attempt, nil)
+}
+
+// retryAfter parses the Retry-After header of 429 and 503 responses.
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil || (res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
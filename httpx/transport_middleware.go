@@ -0,0 +1,68 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"net/http"
+)
+
+// TransportMiddleware wraps a http.RoundTripper, for example to authenticate
+// outgoing requests.
+type TransportMiddleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use a function as http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// ResilientClientWithTransportMiddlewares wraps the transport of the client
+// with the middlewares. The first middleware is the outermost one. The
+// middlewares run once per attempt, so every retry is authenticated anew.
+func ResilientClientWithTransportMiddlewares(mw ...TransportMiddleware) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.middlewares = append(o.middlewares, mw...)
+	}
+}
+
+// ResilientClientWithClientCertificate presents the client certificate
+// returned by getCert in mutual TLS handshakes. Use
+// tlsx.GetClientCertificate to reload the certificate when it changes on disk.
+func ResilientClientWithClientCertificate(getCert func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.getClientCert = getCert
+	}
+}
+
+func wrapTransport(rt http.RoundTripper, mw []TransportMiddleware) http.RoundTripper {
+	for i := len(mw) - 1; i >= 0; i-- {
+		rt = mw[i](rt)
+	}
+	return rt
+}
+
+// withClientCertificate returns a copy of the base transport which presents
+// the client certificate.
+func withClientCertificate(rt http.RoundTripper, getCert func(*tls.CertificateRequestInfo) (*tls.Certificate, error)) http.RoundTripper {
+	switch t := rt.(type) {
+	case nil:
+		return withClientCertificate(http.DefaultTransport, getCert)
+	case *http.Transport:
+		t = t.Clone()
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = new(tls.Config)
+		}
+		t.TLSClientConfig.GetClientCertificate = getCert
+		return t
+	case *egressRoundTripper:
+		return &egressRoundTripper{p: t.p, next: withClientCertificate(t.next, getCert)}
+	case *noInternalIPRoundTripper:
+		return &noInternalIPRoundTripper{
+			onWhitelist:          withClientCertificate(t.onWhitelist, getCert),
+			notOnWhitelist:       withClientCertificate(t.notOnWhitelist, getCert),
+			internalIPExceptions: t.internalIPExceptions,
+		}
+	}
+	return rt
+}
@@ -0,0 +1,249 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+)
+
+// ErrWebhookSignatureInvalid is wrapped by all errors returned for webhooks
+// whose signature could not be verified.
+var ErrWebhookSignatureInvalid = errors.New("webhook signature is invalid")
+
+var errPayloadTooLarge = herodot.DefaultError{
+	StatusField: http.StatusText(http.StatusRequestEntityTooLarge),
+	ErrorField:  "The request payload is too large",
+	CodeField:   http.StatusRequestEntityTooLarge,
+}
+
+// sweepBatch is the number of entries the in-memory stores inspect for expiry
+// on each call, which bounds the work done while holding their lock.
+const sweepBatch = 64
+
+type (
+	// NonceCache remembers nonces to reject replayed requests. Implementations
+	// shared between instances allow replay protection across a cluster.
+	NonceCache interface {
+		// Seen records the nonce for ttl and reports whether it was recorded
+		// already.
+		Seen(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+	}
+
+	// MemoryNonceCache is an in-memory NonceCache.
+	MemoryNonceCache struct {
+		mu     sync.Mutex
+		nonces map[string]time.Time
+		now    func() time.Time
+	}
+
+	// WebhookVerifier verifies HMAC signatures of inbound webhooks as created
+	// by HMACSigning. It can be used as negroni middleware.
+	WebhookVerifier struct {
+		secrets     [][]byte
+		tolerance   time.Duration
+		maxBodySize int64
+		nonces      NonceCache
+		writer      herodot.Writer
+		now         func() time.Time
+	}
+
+	// WebhookVerifierOption configures a WebhookVerifier.
+	WebhookVerifierOption func(*WebhookVerifier)
+)
+
+var _ NonceCache = (*MemoryNonceCache)(nil)
+
+// NewMemoryNonceCache creates a new MemoryNonceCache.
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time), now: time.Now}
+}
+
+// Seen implements NonceCache.
+func (c *MemoryNonceCache) Seen(_ context.Context, nonce string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	sweepExpired(c.nonces, func(expiry time.Time) bool { return now.After(expiry) })
+
+	if expiry, ok := c.nonces[nonce]; ok && !now.After(expiry) {
+		return true, nil
+	}
+	c.nonces[nonce] = now.Add(ttl)
+	return false, nil
+}
+
+// WebhookVerifierWithTolerance sets how far the signature timestamp may
+// deviate from the current time. Defaults to five minutes.
+func WebhookVerifierWithTolerance(tolerance time.Duration) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.tolerance = tolerance
+	}
+}
+
+// WebhookVerifierWithMaxBodySize sets the maximum size of the body which is
+// read to verify the signature. Defaults to 1 MiB.
+func WebhookVerifierWithMaxBodySize(size int64) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.maxBodySize = size
+	}
+}
+
+// WebhookVerifierWithNonceCache sets the cache used to reject replayed
+// requests. Defaults to a MemoryNonceCache.
+func WebhookVerifierWithNonceCache(c NonceCache) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.nonces = c
+	}
+}
+
+// WebhookVerifierWithErrorWriter sets the writer used by ServeHTTP to respond
+// to requests which fail verification. Defaults to a herodot JSON writer.
+func WebhookVerifierWithErrorWriter(w herodot.Writer) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.writer = w
+	}
+}
+
+// WebhookVerifierWithClock sets the clock used to check the timestamp.
+func WebhookVerifierWithClock(now func() time.Time) WebhookVerifierOption {
+	return func(v *WebhookVerifier) {
+		v.now = now
+	}
+}
+
+// NewWebhookVerifier creates a new WebhookVerifier. A signature is accepted if
+// it was created with any of the secrets, so that secrets can be rotated
+// without downtime.
+func NewWebhookVerifier(secrets [][]byte, opts ...WebhookVerifierOption) *WebhookVerifier {
+	v := &WebhookVerifier{
+		secrets:     secrets,
+		tolerance:   5 * time.Minute,
+		maxBodySize: 1 << 20,
+		nonces:      NewMemoryNonceCache(),
+		writer:      herodot.NewJSONWriter(nil),
+		now:         time.Now,
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// Verify verifies the signature of the request. The body is restored so that
+// it can be decoded by the handler. The nonce is derived from the signed
+// content, i.e. the timestamp, method, request URI, and body digest, so a
+// request is only accepted once within the tolerance, regardless of which of
+// its signatures are presented.
+func (v *WebhookVerifier) Verify(r *http.Request) error {
+	timestamp := r.Header.Get(HeaderSignatureTimestamp)
+	signatures := r.Header.Values(HeaderSignature)
+	if timestamp == "" || len(signatures) == 0 {
+		return invalidWebhookSignature("The request is not signed.")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return invalidWebhookSignature("The signature timestamp is malformed.")
+	}
+	if skew := v.now().Sub(time.Unix(unix, 0)).Abs(); skew > v.tolerance {
+		return invalidWebhookSignature("The signature timestamp is outside of the tolerance.")
+	}
+
+	body, err := v.readBody(r)
+	if err != nil {
+		return err
+	}
+
+	digest := contentDigest(body)
+	if d := r.Header.Get(HeaderContentDigest); d != "" && !hmac.Equal([]byte(d), []byte(digest)) {
+		return invalidWebhookSignature("The content digest does not match the body.")
+	}
+
+	var matched bool
+	for _, secret := range v.secrets {
+		expected := []byte(hmacSignature(secret, timestamp, r.Method, r.URL.RequestURI(), digest))
+		for _, values := range signatures {
+			for _, signature := range strings.Split(values, ",") {
+				if hmac.Equal([]byte(strings.TrimSpace(signature)), expected) {
+					matched = true
+				}
+			}
+		}
+	}
+	if !matched {
+		return invalidWebhookSignature("The signature does not match.")
+	}
+
+	seen, err := v.nonces.Seen(r.Context(), webhookNonce(timestamp, r.Method, r.URL.RequestURI(), digest), 2*v.tolerance)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to check the webhook signature for replays.").WithTrace(err))
+	}
+	if seen {
+		return invalidWebhookSignature("The request was replayed.")
+	}
+	return nil
+}
+
+// webhookNonce identifies the signed content of a request.
+func webhookNonce(timestamp, method, requestURI, digest string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{timestamp, method, requestURI, digest}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ServeHTTP implements negroni.Handler.
+func (v *WebhookVerifier) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if err := v.Verify(r); err != nil {
+		v.writer.WriteError(w, r, err)
+		return
+	}
+	next(w, r)
+}
+
+func (v *WebhookVerifier) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, v.maxBodySize+1))
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to read the request body.").WithTrace(err))
+	}
+	_ = r.Body.Close()
+	if int64(len(body)) > v.maxBodySize {
+		return nil, errors.WithStack(errPayloadTooLarge.WithReasonf("The request body is larger than %d bytes.", v.maxBodySize))
+	}
+
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	return body, nil
+}
+
+// sweepExpired deletes expired entries of the map, inspecting at most
+// sweepBatch of them. As map iteration starts at a random entry, all entries
+// are inspected over time.
+func sweepExpired[V any](m map[string]V, expired func(V) bool) {
+	var n int
+	for k, v := range m {
+		if expired(v) {
+			delete(m, k)
+		}
+		if n++; n >= sweepBatch {
+			return
+		}
+	}
+}
+
+func invalidWebhookSignature(reason string) error {
+	return errors.WithStack(herodot.ErrUnauthorized.WithReason(reason).WithWrap(ErrWebhookSignatureInvalid))
+}
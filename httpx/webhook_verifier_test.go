@@ -0,0 +1,49 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ory/herodot"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookVerifierRejectsReplays(t *testing.T) {
+	now := time.Now()
+	secrets := [][]byte{[]byte("old secret"), []byte("new secret")}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := `{"event":"created"}`
+
+	var signatures []string
+	for _, secret := range secrets {
+		signatures = append(signatures, hmacSignature(secret, timestamp, http.MethodPost, "/webhook", contentDigest([]byte(body))))
+	}
+
+	request := func(signatures ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+		r.Header.Set(HeaderSignatureTimestamp, timestamp)
+		r.Header.Set(HeaderSignature, strings.Join(signatures, ", "))
+		return r
+	}
+
+	for _, replayed := range [][]string{
+		signatures,
+		signatures[:1],
+		signatures[1:],
+		{signatures[1], signatures[0]},
+	} {
+		v := NewWebhookVerifier(secrets, WebhookVerifierWithClock(func() time.Time { return now }))
+		require.NoError(t, v.Verify(request(signatures...)))
+
+		err := v.Verify(request(replayed...))
+		assert.ErrorIs(t, err, ErrWebhookSignatureInvalid)
+		var herodotErr *herodot.DefaultError
+		require.ErrorAs(t, err, &herodotErr)
+		assert.Equal(t, "The request was replayed.", herodotErr.Reason())
+	}
+}
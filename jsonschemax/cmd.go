@@ -0,0 +1,130 @@
+package jsonschemax
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/huanggze/x/cmdx"
+)
+
+// NewGenerateTypesCmd returns the "generate-types" command, which generates Go
+// structs or TypeScript interfaces from a JSON Schema.
+func NewGenerateTypesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate-types <schema>",
+		Args:  cobra.ExactArgs(1),
+		Short: "Generate Go structs or TypeScript interfaces from a JSON Schema",
+		Long: `Generate Go structs or TypeScript interfaces from a JSON Schema file or URL.
+
+The generated code is written to standard output unless --output is set.`,
+		Example: `generate-types --lang go --package config --type Config config.schema.json
+generate-types --lang typescript --type Identity https://example.com/identity.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			lang, _ := flags.GetString("lang")
+			pkg, _ := flags.GetString("package")
+			rootType, _ := flags.GetString("type")
+			output, _ := flags.GetString("output")
+
+			ref, err := schemaRef(args[0])
+			if err != nil {
+				return err
+			}
+
+			opts := []GeneratorOption{GeneratorWithPackageName(pkg), GeneratorWithRootType(rootType)}
+
+			var generated []byte
+			switch lang {
+			case "go":
+				generated, err = GenerateGo(cmd.Context(), ref, jsonschema.NewCompiler(), opts...)
+			case "ts", "typescript":
+				generated, err = GenerateTypeScript(cmd.Context(), ref, jsonschema.NewCompiler(), opts...)
+			default:
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Unknown language %q, expected go or typescript.\n", lang)
+				return cmdx.FailSilently(cmd)
+			}
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not generate types from %s:\n%+v\n", args[0], err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			if output == "" {
+				_, err = cmd.OutOrStdout().Write(generated)
+				return err
+			}
+			return os.WriteFile(output, generated, 0644)
+		},
+	}
+	cmd.Flags().StringP("lang", "l", "go", "The language to generate: go or typescript.")
+	cmd.Flags().String("package", "schema", "The package name of generated Go code.")
+	cmd.Flags().String("type", "Config", "The name of the root type, which also prefixes nested types.")
+	cmd.Flags().StringP("output", "o", "", "The file to write to instead of standard output.")
+	return cmd
+}
+
+// NewCompareSchemasCmd returns the "compare-schemas" command, which reports the
+// changes between two versions of a JSON Schema. It fails if a change is
+// breaking, unless --allow-breaking is set.
+func NewCompareSchemasCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "compare-schemas <old> <new>",
+		Args:  cobra.ExactArgs(2),
+		Short: "Report the changes between two versions of a JSON Schema",
+		Long: `Report the changes between two versions of a JSON Schema file or URL.
+
+Breaking changes are changes after which documents that were valid might no longer be valid or
+might be interpreted differently, e.g. removed properties, new required properties, changed types,
+narrowed enums and ranges, new patterns, and changed defaults. The command fails if there are
+breaking changes, unless --allow-breaking is set.`,
+		Example: "compare-schemas --format json config.schema.old.json config.schema.json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			allowBreaking, _ := cmd.Flags().GetBool("allow-breaking")
+			maxRecursion, _ := cmd.Flags().GetUint8("max-recursion")
+
+			oldRef, err := schemaRef(args[0])
+			if err != nil {
+				return err
+			}
+			newRef, err := schemaRef(args[1])
+			if err != nil {
+				return err
+			}
+
+			changes, err := CompareSchemas(cmd.Context(), oldRef, jsonschema.NewCompiler(), newRef, jsonschema.NewCompiler(), CompareSchemasWithMaxRecursion(maxRecursion))
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not compare the schemas:\n%+v\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			cmdx.PrintTable(cmd, changes)
+			if breaking := changes.Breaking(); len(breaking) > 0 && !allowBreaking {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Found %d breaking change(s).\n", len(breaking))
+				return cmdx.FailSilently(cmd)
+			}
+			return nil
+		},
+	}
+	cmdx.RegisterFormatFlags(cmd.Flags())
+	cmd.Flags().Bool("allow-breaking", false, "Do not fail if there are breaking changes.")
+	cmd.Flags().Uint8("max-recursion", 5, "How often circular references are followed. Changes in deeper levels are not reported.")
+	return cmd
+}
+
+// schemaRef converts a file path to a file URL. URLs are returned as is.
+func schemaRef(location string) (string, error) {
+	if u, err := url.Parse(location); err == nil && len(u.Scheme) > 1 {
+		return location, nil
+	}
+
+	abs, err := filepath.Abs(location)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
@@ -0,0 +1,395 @@
+package jsonschemax
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/cmdx"
+)
+
+// ErrIncompatibleSchema is returned by SchemaChanges.Err if a schema change is
+// breaking.
+var ErrIncompatibleSchema = errors.New("the schema change is not backwards compatible")
+
+// Kinds of schema changes.
+const (
+	ChangePropertyAdded      = "property_added"
+	ChangePropertyRemoved    = "property_removed"
+	ChangeRequiredAdded      = "required_added"
+	ChangeRequiredRemoved    = "required_removed"
+	ChangeTypeChanged        = "type_changed"
+	ChangeEnumNarrowed       = "enum_narrowed"
+	ChangeEnumWidened        = "enum_widened"
+	ChangeConstChanged       = "const_changed"
+	ChangePatternChanged     = "pattern_changed"
+	ChangeFormatChanged      = "format_changed"
+	ChangeRangeNarrowed      = "range_narrowed"
+	ChangeRangeWidened       = "range_widened"
+	ChangeMultipleOfChanged  = "multiple_of_changed"
+	ChangeDefaultChanged     = "default_changed"
+	ChangeDescriptionChanged = "description_changed"
+)
+
+type (
+	// SchemaChange is a difference between two versions of a schema.
+	SchemaChange struct {
+		// Path is the dot-notation path of the changed property, e.g.
+		// "serve.tls.cert_path". Array items are "#".
+		Path string `json:"path"`
+		// Kind is the kind of change, e.g. ChangePropertyRemoved.
+		Kind string `json:"kind"`
+		// Breaking is true if documents which are valid against the old
+		// schema might not be valid against the new schema, or might be
+		// interpreted differently.
+		Breaking bool `json:"breaking"`
+		// Old is the old value of the changed keyword, if any.
+		Old interface{} `json:"old,omitempty"`
+		// New is the new value of the changed keyword, if any.
+		New interface{} `json:"new,omitempty"`
+		// Message describes the change.
+		Message string `json:"message"`
+	}
+
+	// SchemaChanges is the result of CompareSchemas. It can be printed with
+	// cmdx.PrintTable.
+	SchemaChanges []SchemaChange
+
+	// CompareSchemasOption configures CompareSchemas.
+	CompareSchemasOption func(*compareSchemasOptions)
+
+	compareSchemasOptions struct {
+		maxRecursion uint8
+	}
+)
+
+// CompareSchemasWithMaxRecursion sets how often circular references are
+// followed. Changes in deeper levels of recursive schemas are not reported.
+// Defaults to 5.
+func CompareSchemasWithMaxRecursion(depth uint8) CompareSchemasOption {
+	return func(o *compareSchemasOptions) {
+		o.maxRecursion = depth
+	}
+}
+
+var _ cmdx.Table = (SchemaChanges)(nil)
+
+// CompareSchemas compares two versions of a schema and returns the changes
+// of all paths, sorted by path. Breaking changes are removed properties, new
+// required properties, changed types, narrowed enums and ranges, new or
+// changed patterns and formats, and changed defaults. New required properties
+// are breaking even if they have a default, as defaults are not applied
+// during validation. Circular references are followed up to the depth set
+// with CompareSchemasWithMaxRecursion.
+func CompareSchemas(ctx context.Context, oldRef string, oldCompiler *jsonschema.Compiler, newRef string, newCompiler *jsonschema.Compiler, opts ...CompareSchemasOption) (SchemaChanges, error) {
+	o := &compareSchemasOptions{maxRecursion: 5}
+	for _, f := range opts {
+		f(o)
+	}
+
+	oldPaths, err := runPathsFromCompiler(ctx, oldRef, oldCompiler, int16(o.maxRecursion), true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the paths of schema %s", oldRef)
+	}
+	newPaths, err := runPathsFromCompiler(ctx, newRef, newCompiler, int16(o.maxRecursion), true)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the paths of schema %s", newRef)
+	}
+
+	oldByName := make(map[string]Path, len(oldPaths))
+	for _, p := range oldPaths {
+		oldByName[p.Name] = p
+	}
+	newByName := make(map[string]Path, len(newPaths))
+	for _, p := range newPaths {
+		newByName[p.Name] = p
+	}
+
+	changes := SchemaChanges{}
+	var removed, added []string
+	for _, o := range oldPaths {
+		n, ok := newByName[o.Name]
+		if !ok {
+			if !hasParent(removed, o.Name) {
+				removed = append(removed, o.Name)
+				changes = append(changes, SchemaChange{
+					Path: o.Name, Kind: ChangePropertyRemoved, Breaking: true,
+					Message: "The property was removed.",
+				})
+			}
+			continue
+		}
+		changes = append(changes, comparePaths(o, n)...)
+	}
+
+	for _, n := range newPaths {
+		if _, ok := oldByName[n.Name]; ok || hasParent(added, n.Name) {
+			continue
+		}
+		added = append(added, n.Name)
+		if n.Required {
+			changes = append(changes, SchemaChange{
+				Path: n.Name, Kind: ChangeRequiredAdded, Breaking: true,
+				Message: "A required property was added.",
+			})
+			continue
+		}
+		changes = append(changes, SchemaChange{
+			Path: n.Name, Kind: ChangePropertyAdded,
+			Message: "An optional property was added.",
+		})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+func hasParent(parents []string, name string) bool {
+	for _, parent := range parents {
+		if strings.HasPrefix(name, parent+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func comparePaths(o, n Path) (changes SchemaChanges) {
+	add := func(kind string, breaking bool, old, new interface{}, message string) {
+		changes = append(changes, SchemaChange{Path: o.Name, Kind: kind, Breaking: breaking, Old: old, New: new, Message: message})
+	}
+
+	if !o.Required && n.Required {
+		add(ChangeRequiredAdded, true, nil, nil, "The property became required.")
+	} else if o.Required && !n.Required {
+		add(ChangeRequiredRemoved, false, nil, nil, "The property became optional.")
+	}
+
+	if o.TypeHint != n.TypeHint && n.TypeHint != 0 {
+		widened := (o.TypeHint == Int && n.TypeHint == Float) || (o.TypeHint == IntSlice && n.TypeHint == FloatSlice)
+		add(ChangeTypeChanged, !widened, typeName(o), typeName(n), fmt.Sprintf("The type changed from %s to %s.", typeName(o), typeName(n)))
+	}
+
+	if len(n.Constant) > 0 && (len(o.Constant) == 0 || !jsonEqual(o.Constant[0], n.Constant[0])) {
+		var old interface{}
+		if len(o.Constant) > 0 {
+			old = o.Constant[0]
+		}
+		add(ChangeConstChanged, true, old, n.Constant[0], "The constant value changed.")
+	}
+
+	switch {
+	case len(n.Enum) > 0 && len(o.Enum) == 0:
+		add(ChangeEnumNarrowed, true, nil, n.Enum, "The values were restricted to an enum.")
+	case len(n.Enum) == 0 && len(o.Enum) > 0:
+		add(ChangeEnumWidened, false, o.Enum, nil, "The enum was removed.")
+	case len(n.Enum) > 0:
+		if removed := enumDifference(o.Enum, n.Enum); len(removed) > 0 {
+			add(ChangeEnumNarrowed, true, o.Enum, n.Enum, fmt.Sprintf("The enum values %v were removed.", removed))
+		} else if addedValues := enumDifference(n.Enum, o.Enum); len(addedValues) > 0 {
+			add(ChangeEnumWidened, false, o.Enum, n.Enum, fmt.Sprintf("The enum values %v were added.", addedValues))
+		}
+	}
+
+	if oldPattern, newPattern := patternString(o), patternString(n); oldPattern != newPattern {
+		add(ChangePatternChanged, newPattern != "", nullable(oldPattern), nullable(newPattern), "The pattern changed.")
+	}
+
+	if o.Format != n.Format {
+		add(ChangeFormatChanged, n.Format != "", nullable(o.Format), nullable(n.Format), "The format changed.")
+	}
+
+	compareBound := func(keyword string, old, new int, isMinimum bool) {
+		if old == new {
+			return
+		}
+		narrowed := new != -1 && (old == -1 || (isMinimum && new > old) || (!isMinimum && new < old))
+		add(rangeKind(narrowed), narrowed, boundValue(old), boundValue(new), fmt.Sprintf("The %s changed.", keyword))
+	}
+	compareBound("minLength", o.MinLength, n.MinLength, true)
+	compareBound("maxLength", o.MaxLength, n.MaxLength, false)
+
+	compareNumber := func(keyword string, old, new *big.Float, isMinimum bool) {
+		if (old == nil && new == nil) || (old != nil && new != nil && old.Cmp(new) == 0) {
+			return
+		}
+		narrowed := new != nil && (old == nil || (isMinimum && new.Cmp(old) > 0) || (!isMinimum && new.Cmp(old) < 0))
+		add(rangeKind(narrowed), narrowed, floatValue(old), floatValue(new), fmt.Sprintf("The %s changed.", keyword))
+	}
+	compareNumber("minimum", o.Minimum, n.Minimum, true)
+	compareNumber("maximum", o.Maximum, n.Maximum, false)
+
+	if (o.MultipleOf == nil) != (n.MultipleOf == nil) || (o.MultipleOf != nil && o.MultipleOf.Cmp(n.MultipleOf) != 0) {
+		add(ChangeMultipleOfChanged, n.MultipleOf != nil, floatValue(o.MultipleOf), floatValue(n.MultipleOf), "The multipleOf changed.")
+	}
+
+	if !jsonEqual(o.Default, n.Default) {
+		add(ChangeDefaultChanged, true, o.Default, n.Default, "The default value changed.")
+	}
+
+	if o.Description != n.Description || o.Title != n.Title {
+		add(ChangeDescriptionChanged, false, nil, nil, "The title or description changed.")
+	}
+
+	return changes
+}
+
+func rangeKind(narrowed bool) string {
+	if narrowed {
+		return ChangeRangeNarrowed
+	}
+	return ChangeRangeWidened
+}
+
+func typeName(p Path) string {
+	switch p.TypeHint {
+	case String:
+		return "string"
+	case Float:
+		return "number"
+	case Int:
+		return "integer"
+	case Bool:
+		return "boolean"
+	case JSON:
+		if _, ok := p.Type.([]interface{}); ok {
+			return "array"
+		}
+		return "object"
+	case Nil:
+		return "null"
+	case BoolSlice:
+		return "boolean[]"
+	case StringSlice:
+		return "string[]"
+	case IntSlice:
+		return "integer[]"
+	case FloatSlice:
+		return "number[]"
+	}
+	return "any"
+}
+
+func patternString(p Path) string {
+	if p.Pattern == nil {
+		return ""
+	}
+	return p.Pattern.String()
+}
+
+func nullable(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
+func boundValue(v int) interface{} {
+	if v == -1 {
+		return nil
+	}
+	return v
+}
+
+func floatValue(v *big.Float) interface{} {
+	if v == nil {
+		return nil
+	}
+	f, _ := v.Float64()
+	return f
+}
+
+func jsonEqual(a, b interface{}) bool {
+	ea, errA := json.Marshal(a)
+	eb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return reflect.DeepEqual(a, b)
+	}
+	var va, vb interface{}
+	_ = json.Unmarshal(ea, &va)
+	_ = json.Unmarshal(eb, &vb)
+	return reflect.DeepEqual(va, vb)
+}
+
+// enumDifference returns the values of a which are not in b.
+func enumDifference(a, b []interface{}) []interface{} {
+	var diff []interface{}
+	for _, va := range a {
+		found := false
+		for _, vb := range b {
+			if jsonEqual(va, vb) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, va)
+		}
+	}
+	return diff
+}
+
+// Breaking returns the breaking changes.
+func (c SchemaChanges) Breaking() SchemaChanges {
+	var breaking SchemaChanges
+	for _, change := range c {
+		if change.Breaking {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// Err returns an error wrapping ErrIncompatibleSchema which lists the
+// breaking changes, or nil if there are none. It is meant for assertions:
+//
+//	require.NoError(t, changes.Err())
+func (c SchemaChanges) Err() error {
+	breaking := c.Breaking()
+	if len(breaking) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(breaking))
+	for k, change := range breaking {
+		messages[k] = fmt.Sprintf("%s (%s): %s", change.Path, change.Kind, change.Message)
+	}
+	return errors.Wrap(ErrIncompatibleSchema, strings.Join(messages, "; "))
+}
+
+func (c SchemaChanges) Header() []string {
+	return []string{"PATH", "KIND", "BREAKING", "OLD", "NEW", "MESSAGE"}
+}
+
+func (c SchemaChanges) Table() [][]string {
+	rows := make([][]string, len(c))
+	for k, change := range c {
+		rows[k] = []string{change.Path, change.Kind, fmt.Sprintf("%t", change.Breaking), tableValue(change.Old), tableValue(change.New), change.Message}
+	}
+	return rows
+}
+
+func (c SchemaChanges) Interface() interface{} {
+	return c
+}
+
+func (c SchemaChanges) Len() int {
+	return len(c)
+}
+
+func tableValue(v interface{}) string {
+	if v == nil {
+		return cmdx.None
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(out)
+}
@@ -0,0 +1,740 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/randx"
+)
+
+// ErrFakeFailed is returned when the Faker can not generate a document for
+// the schema.
+var ErrFakeFailed = errors.New("unable to generate a document for the schema")
+
+type (
+	// FakerOption configures a Faker.
+	FakerOption func(*Faker)
+
+	// Faker generates random documents for a JSON Schema. The documents are
+	// reproducible: a Faker with the same schema and seed always generates
+	// the same sequence of documents. A Faker is not safe for concurrent use.
+	Faker struct {
+		schema       *jsonschema.Schema
+		rand         *randx.Seeded
+		maxRecursion int
+		maxAttempts  int
+
+		stack     map[*jsonschema.Schema]int
+		mutations []mutation
+	}
+
+	// NearMiss describes how a near-miss document violates the schema.
+	NearMiss struct {
+		// Pointer is the RFC 6901 JSON Pointer of the invalid value.
+		Pointer string `json:"pointer"`
+		// Keyword is the JSON Schema keyword which is violated.
+		Keyword string `json:"keyword"`
+	}
+
+	mutation struct {
+		NearMiss
+		// apply changes the document and returns a function which reverts
+		// the change.
+		apply func() (revert func())
+	}
+)
+
+// requiredRecursionLimit is how often circular references are followed past
+// the maximum recursion depth if the schema requires them.
+const requiredRecursionLimit = 10
+
+var formatFakers = map[string]func(r *randx.Seeded) string{
+	"email": func(r *randx.Seeded) string {
+		return r.String(8, randx.AlphaLowerNum) + "@" + r.String(6, randx.AlphaLower) + ".example.com"
+	},
+	"uri": func(r *randx.Seeded) string {
+		return "https://" + r.String(6, randx.AlphaLower) + ".example.com/" + r.String(8, randx.AlphaLowerNum)
+	},
+	"uri-reference": func(r *randx.Seeded) string {
+		return "/" + r.String(8, randx.AlphaLowerNum)
+	},
+	"uuid": func(r *randx.Seeded) string {
+		hex := []rune("0123456789abcdef")
+		return r.String(8, hex) + "-" + r.String(4, hex) + "-4" + r.String(3, hex) + "-" +
+			r.String(1, []rune("89ab")) + r.String(3, hex) + "-" + r.String(12, hex)
+	},
+	"date-time": func(r *randx.Seeded) string {
+		return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02dZ", r.Int64Range(1970, 2100), r.Int64Range(1, 12), r.Int64Range(1, 28),
+			r.Int64Range(0, 23), r.Int64Range(0, 59), r.Int64Range(0, 59))
+	},
+	"date": func(r *randx.Seeded) string {
+		return fmt.Sprintf("%04d-%02d-%02d", r.Int64Range(1970, 2100), r.Int64Range(1, 12), r.Int64Range(1, 28))
+	},
+	"time": func(r *randx.Seeded) string {
+		return fmt.Sprintf("%02d:%02d:%02dZ", r.Int64Range(0, 23), r.Int64Range(0, 59), r.Int64Range(0, 59))
+	},
+	"hostname": func(r *randx.Seeded) string {
+		return r.String(8, randx.AlphaLower) + ".example.com"
+	},
+	"ipv4": func(r *randx.Seeded) string {
+		return fmt.Sprintf("%d.%d.%d.%d", r.Int64Range(1, 223), r.Int64Range(0, 255), r.Int64Range(0, 255), r.Int64Range(1, 254))
+	},
+	"ipv6": func(r *randx.Seeded) string {
+		return fmt.Sprintf("2001:db8::%x:%x", r.Int64Range(0, 0xffff), r.Int64Range(1, 0xffff))
+	},
+}
+
+var invalidFormats = map[string]string{
+	"email":         "near-miss.example.com",
+	"uri":           "near miss",
+	"uri-reference": "\\\\near miss",
+	"uuid":          "0000-near-miss",
+	"date-time":     "2006-13-45T25:61:00Z",
+	"date":          "2006-13-45",
+	"time":          "25:61:00Z",
+	"hostname":      "-near-miss-",
+	"ipv4":          "256.256.256.256",
+	"ipv6":          "2001:db8:::near:miss",
+}
+
+func init() {
+	formatFakers["idn-email"] = formatFakers["email"]
+	formatFakers["iri"] = formatFakers["uri"]
+	formatFakers["iri-reference"] = formatFakers["uri-reference"]
+	formatFakers["idn-hostname"] = formatFakers["hostname"]
+	invalidFormats["idn-email"] = invalidFormats["email"]
+	invalidFormats["iri"] = invalidFormats["uri"]
+	invalidFormats["idn-hostname"] = invalidFormats["hostname"]
+}
+
+// FakerWithSeed sets the seed of the Faker. Defaults to 0.
+func FakerWithSeed(seed uint64) FakerOption {
+	return func(f *Faker) {
+		f.rand = randx.NewSeeded(seed)
+	}
+}
+
+// FakerWithMaxRecursion sets how often circular references are followed
+// before only required properties and the minimum number of array items are
+// generated. Defaults to 3.
+func FakerWithMaxRecursion(depth uint8) FakerOption {
+	return func(f *Faker) {
+		f.maxRecursion = int(depth)
+	}
+}
+
+// NewFaker returns a Faker for the compiled schema.
+func NewFaker(schema *jsonschema.Schema, opts ...FakerOption) *Faker {
+	f := &Faker{
+		schema:       schema,
+		rand:         randx.NewSeeded(0),
+		maxRecursion: 3,
+		maxAttempts:  20,
+	}
+	for _, o := range opts {
+		o(f)
+	}
+	return f
+}
+
+// Fake generates a random document which is valid against the schema. It
+// respects types, enums, constants, formats, patterns, lengths, ranges, and
+// item and property counts. Schemas the Faker can not satisfy, for example
+// because of "not" or conflicting "allOf" branches, return ErrFakeFailed.
+func (f *Faker) Fake() (json.RawMessage, error) {
+	var last error
+	for attempt := 0; attempt < f.maxAttempts; attempt++ {
+		doc, err := f.generate()
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(doc.v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if last = f.schema.Validate(bytes.NewReader(raw)); last == nil {
+			return raw, nil
+		}
+	}
+	return nil, errors.Wrap(ErrFakeFailed, last.Error())
+}
+
+// FakeNearMiss generates a document which violates exactly one constraint of
+// the schema, e.g. a missing required property or a string which is one
+// character too short. It is useful for negative tests.
+func (f *Faker) FakeNearMiss() (json.RawMessage, *NearMiss, error) {
+	for attempt := 0; attempt < f.maxAttempts; attempt++ {
+		doc, err := f.generate()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		raw, err := json.Marshal(doc.v)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		} else if f.schema.Validate(bytes.NewReader(raw)) != nil {
+			continue
+		}
+
+		// Type changes are only used if no other constraint can be violated,
+		// because they apply to every value.
+		var mutations, typeMutations []mutation
+		for _, m := range f.mutations {
+			if m.Keyword == "type" {
+				typeMutations = append(typeMutations, m)
+			} else {
+				mutations = append(mutations, m)
+			}
+		}
+
+		for len(mutations)+len(typeMutations) > 0 {
+			if len(mutations) == 0 {
+				mutations, typeMutations = typeMutations, nil
+			}
+			k := f.rand.IntN(len(mutations))
+			m := mutations[k]
+			mutations = append(mutations[:k:k], mutations[k+1:]...)
+
+			revert := m.apply()
+			raw, err := json.Marshal(doc.v)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			if f.schema.Validate(bytes.NewReader(raw)) != nil {
+				nearMiss := m.NearMiss
+				return raw, &nearMiss, nil
+			}
+			revert()
+		}
+	}
+	return nil, nil, errors.Wrap(ErrFakeFailed, "no constraint could be violated")
+}
+
+type document struct {
+	v interface{}
+}
+
+func (f *Faker) generate() (*document, error) {
+	f.stack = map[*jsonschema.Schema]int{}
+	f.mutations = nil
+
+	doc := new(document)
+	if err := f.value(f.schema, "", func(v interface{}) { doc.v = v }); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func (f *Faker) mutate(pointer, keyword string, apply func() (revert func())) {
+	f.mutations = append(f.mutations, mutation{NearMiss: NearMiss{Pointer: pointer, Keyword: keyword}, apply: apply})
+}
+
+// replace registers a mutation which replaces the value at the pointer.
+func (f *Faker) replace(pointer, keyword string, set func(interface{}), original interface{}, invalid func() interface{}) {
+	f.mutate(pointer, keyword, func() func() {
+		set(invalid())
+		return func() { set(original) }
+	})
+}
+
+func (f *Faker) value(s *jsonschema.Schema, pointer string, set func(interface{})) error {
+	f.stack[s]++
+	defer func() { f.stack[s]-- }()
+	if f.stack[s] > f.maxRecursion+requiredRecursionLimit {
+		return errors.Wrapf(ErrFakeFailed, "the schema requires circular references at %q", pointer)
+	}
+
+	switch {
+	case s.Always != nil:
+		if !*s.Always {
+			return errors.Wrapf(ErrFakeFailed, "the schema at %q never validates", pointer)
+		}
+		v := f.rand.String(8, randx.AlphaLower)
+		set(v)
+		return nil
+	case s.Ref != nil:
+		return f.value(s.Ref, pointer, set)
+	case len(s.Constant) > 0:
+		set(s.Constant[0])
+		f.replace(pointer, "const", set, s.Constant[0], func() interface{} { return f.notIn(s.Constant) })
+		return nil
+	case len(s.Enum) > 0:
+		v := s.Enum[f.rand.IntN(len(s.Enum))]
+		set(v)
+		f.replace(pointer, "enum", set, v, func() interface{} { return f.notIn(s.Enum) })
+		return nil
+	case len(s.OneOf) > 0:
+		return f.value(s.OneOf[f.rand.IntN(len(s.OneOf))], pointer, set)
+	case len(s.AnyOf) > 0:
+		return f.value(s.AnyOf[f.rand.IntN(len(s.AnyOf))], pointer, set)
+	}
+
+	var v interface{}
+	t := f.typeOf(s)
+	switch t {
+	case "object":
+		obj := map[string]interface{}{}
+		v = obj
+		set(obj)
+		for _, sub := range append([]*jsonschema.Schema{s}, s.AllOf...) {
+			for sub.Ref != nil {
+				sub = sub.Ref
+			}
+			if err := f.object(sub, s, pointer, obj); err != nil {
+				return err
+			}
+		}
+	case "array":
+		var err error
+		if v, err = f.array(s, pointer, set); err != nil {
+			return err
+		}
+	case "string":
+		v = f.string(s, pointer, set)
+	case "integer", "number":
+		v = f.number(s, t == "integer", pointer, set)
+	case "boolean":
+		v = f.rand.Bool()
+		set(v)
+	case "null":
+		set(nil)
+	default:
+		if len(s.AllOf) > 0 {
+			return f.value(s.AllOf[0], pointer, set)
+		}
+		v = f.rand.String(8, randx.AlphaLower)
+		set(v)
+		return nil
+	}
+
+	f.replace(pointer, "type", set, v, func() interface{} {
+		switch t {
+		case "string":
+			return 42
+		case "boolean":
+			return "true"
+		case "object":
+			return []interface{}{}
+		case "array":
+			return map[string]interface{}{}
+		case "null":
+			return false
+		}
+		return "42"
+	})
+	return nil
+}
+
+func (f *Faker) typeOf(s *jsonschema.Schema) string {
+	if len(s.Types) > 0 {
+		return s.Types[f.rand.IntN(len(s.Types))]
+	}
+
+	switch {
+	case len(s.Properties) > 0 || len(s.Required) > 0 || s.AdditionalProperties != nil || s.MinProperties > 0:
+		return "object"
+	case s.Items != nil || s.MinItems > 0 || s.MaxItems != -1:
+		return "array"
+	case s.Format != "" || s.Pattern != nil || s.MinLength > 0 || s.MaxLength != -1:
+		return "string"
+	case s.Minimum != nil || s.Maximum != nil || s.ExclusiveMinimum != nil || s.ExclusiveMaximum != nil || s.MultipleOf != nil:
+		return "number"
+	}
+
+	for _, sub := range s.AllOf {
+		for sub.Ref != nil {
+			sub = sub.Ref
+		}
+		if t := f.typeOf(sub); t != "" {
+			return t
+		}
+	}
+	return ""
+}
+
+// object adds the properties of the schema to obj. recursion is the schema
+// whose recursion depth decides whether optional properties are generated.
+func (f *Faker) object(s, recursion *jsonschema.Schema, pointer string, obj map[string]interface{}) error {
+	deep := f.stack[recursion] > f.maxRecursion
+
+	required := make(map[string]bool, len(s.Required))
+	for _, name := range s.Required {
+		required[name] = true
+	}
+
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var skipped []string
+	for _, name := range names {
+		if !required[name] && (deep || f.rand.Bool()) {
+			skipped = append(skipped, name)
+			continue
+		}
+		if err := f.property(s.Properties[name], pointer, name, obj); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			if err := f.property(&jsonschema.Schema{MinLength: -1, MaxLength: -1, Types: []string{"string"}}, pointer, name, obj); err != nil {
+				return err
+			}
+		}
+	}
+
+	for len(obj) < s.MinProperties {
+		if len(skipped) > 0 {
+			name := skipped[0]
+			skipped = skipped[1:]
+			if err := f.property(s.Properties[name], pointer, name, obj); err != nil {
+				return err
+			}
+			continue
+		}
+
+		additional, ok := s.AdditionalProperties.(*jsonschema.Schema)
+		if !ok {
+			if allowed, ok := s.AdditionalProperties.(bool); ok && !allowed {
+				break
+			}
+			additional = &jsonschema.Schema{MinLength: -1, MaxLength: -1, Types: []string{"string"}}
+		}
+		if err := f.property(additional, pointer, "additional_"+strconv.Itoa(len(obj)), obj); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range s.Required {
+		name := name
+		f.mutate(pointer+"/"+escapePointer(name), "required", func() func() {
+			original := obj[name]
+			delete(obj, name)
+			return func() { obj[name] = original }
+		})
+	}
+
+	if allowed, ok := s.AdditionalProperties.(bool); ok && !allowed {
+		name := "near_miss_" + f.rand.String(6, randx.AlphaLower)
+		f.mutate(pointer+"/"+escapePointer(name), "additionalProperties", func() func() {
+			obj[name] = "near-miss"
+			return func() { delete(obj, name) }
+		})
+	}
+
+	return nil
+}
+
+func (f *Faker) property(s *jsonschema.Schema, pointer, name string, obj map[string]interface{}) error {
+	return f.value(s, pointer+"/"+escapePointer(name), func(v interface{}) { obj[name] = v })
+}
+
+func (f *Faker) array(s *jsonschema.Schema, pointer string, set func(interface{})) ([]interface{}, error) {
+	minItems, maxItems := max(s.MinItems, 0), s.MaxItems
+	if maxItems == -1 || maxItems > minItems+3 {
+		maxItems = minItems + 3
+	}
+	if f.stack[s] > f.maxRecursion {
+		maxItems = minItems
+	}
+
+	arr := make([]interface{}, f.rand.Int64Range(int64(minItems), int64(maxItems)))
+	set(arr)
+
+	seen := map[string]bool{}
+	for i := range arr {
+		items := &jsonschema.Schema{MinLength: -1, MaxLength: -1, Types: []string{"string"}}
+		switch t := s.Items.(type) {
+		case *jsonschema.Schema:
+			items = t
+		case []*jsonschema.Schema:
+			if i < len(t) {
+				items = t[i]
+			} else if additional, ok := s.AdditionalItems.(*jsonschema.Schema); ok {
+				items = additional
+			}
+		}
+
+		for attempt := 0; attempt < f.maxAttempts; attempt++ {
+			if err := f.value(items, pointer+"/"+strconv.Itoa(i), func(v interface{}) { arr[i] = v }); err != nil {
+				return nil, err
+			}
+			if !s.UniqueItems {
+				break
+			}
+			key, _ := json.Marshal(arr[i])
+			if !seen[string(key)] {
+				seen[string(key)] = true
+				break
+			}
+		}
+	}
+
+	if s.MinItems > 0 {
+		f.replace(pointer, "minItems", set, arr, func() interface{} { return arr[:s.MinItems-1] })
+	}
+	if s.MaxItems != -1 && len(arr) > 0 {
+		f.replace(pointer, "maxItems", set, arr, func() interface{} {
+			longer := append([]interface{}{}, arr...)
+			for len(longer) <= s.MaxItems {
+				longer = append(longer, arr[0])
+			}
+			return longer
+		})
+	}
+	if s.UniqueItems && len(arr) > 0 {
+		f.replace(pointer, "uniqueItems", set, arr, func() interface{} {
+			return append(append([]interface{}{}, arr...), arr[0])
+		})
+	}
+
+	return arr, nil
+}
+
+func (f *Faker) string(s *jsonschema.Schema, pointer string, set func(interface{})) string {
+	minLength, maxLength := max(s.MinLength, 0), s.MaxLength
+	if maxLength == -1 || maxLength > minLength+16 {
+		maxLength = minLength + 16
+	}
+
+	valid := func(v string) bool {
+		l := utf8.RuneCountInString(v)
+		return l >= minLength && (s.MaxLength == -1 || l <= s.MaxLength) && (s.Pattern == nil || s.Pattern.MatchString(v))
+	}
+
+	var v string
+	for attempt := 0; attempt < f.maxAttempts; attempt++ {
+		if fake, ok := formatFakers[s.Format]; ok {
+			v = fake(f.rand)
+		} else if pattern, ok := f.pattern(s.Pattern); ok {
+			v = pattern
+		} else {
+			v = f.rand.String(int(f.rand.Int64Range(int64(min(max(minLength, 1), maxLength)), int64(maxLength))), randx.AlphaNum)
+		}
+		if valid(v) {
+			break
+		}
+	}
+	set(v)
+
+	if s.MinLength > 0 {
+		f.replace(pointer, "minLength", set, v, func() interface{} {
+			return string([]rune(v + f.rand.String(s.MinLength, randx.AlphaNum))[:s.MinLength-1])
+		})
+	}
+	if s.MaxLength != -1 {
+		f.replace(pointer, "maxLength", set, v, func() interface{} {
+			return v + f.rand.String(s.MaxLength+1, randx.AlphaNum)
+		})
+	}
+	if invalid, ok := invalidFormats[s.Format]; ok {
+		f.replace(pointer, "format", set, v, func() interface{} { return invalid })
+	}
+	if s.Pattern != nil {
+		f.replace(pointer, "pattern", set, v, func() interface{} {
+			for _, candidate := range []string{"", " ", "!near-miss!", "\x00"} {
+				if !s.Pattern.MatchString(candidate) {
+					return candidate
+				}
+			}
+			return v
+		})
+	}
+
+	return v
+}
+
+func (f *Faker) number(s *jsonschema.Schema, integer bool, pointer string, set func(interface{})) interface{} {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if s.Minimum != nil {
+		lo, _ = s.Minimum.Float64()
+	}
+	if s.ExclusiveMinimum != nil {
+		exclusive, _ := s.ExclusiveMinimum.Float64()
+		lo = math.Max(lo, math.Nextafter(exclusive, math.Inf(1)))
+	}
+	if s.Maximum != nil {
+		hi, _ = s.Maximum.Float64()
+	}
+	if s.ExclusiveMaximum != nil {
+		exclusive, _ := s.ExclusiveMaximum.Float64()
+		hi = math.Min(hi, math.Nextafter(exclusive, math.Inf(-1)))
+	}
+	switch {
+	case math.IsInf(lo, -1) && math.IsInf(hi, 1):
+		lo, hi = -1000, 1000
+	case math.IsInf(lo, -1):
+		lo = hi - 1000
+	case math.IsInf(hi, 1):
+		hi = lo + 1000
+	}
+
+	var v interface{}
+	var step float64
+	if s.MultipleOf != nil {
+		step, _ = s.MultipleOf.Float64()
+	}
+	switch {
+	case step > 0:
+		k := float64(f.rand.Int64Range(int64(math.Ceil(lo/step)), int64(math.Floor(hi/step))))
+		if integer && step == math.Trunc(step) {
+			v = int64(k * step)
+		} else {
+			v = k * step
+		}
+	case integer:
+		v = f.rand.Int64Range(int64(math.Ceil(lo)), int64(math.Floor(hi)))
+	default:
+		v = math.Round((lo+f.rand.Float64()*(hi-lo))*100) / 100
+		if x := v.(float64); x < lo || x > hi {
+			v = lo
+		}
+	}
+	set(v)
+
+	bound := func(keyword string, b *big.Float, delta float64) {
+		if b == nil {
+			return
+		}
+		x, _ := b.Float64()
+		f.replace(pointer, keyword, set, v, func() interface{} { return x + delta })
+	}
+	bound("minimum", s.Minimum, -1)
+	bound("exclusiveMinimum", s.ExclusiveMinimum, 0)
+	bound("maximum", s.Maximum, 1)
+	bound("exclusiveMaximum", s.ExclusiveMaximum, 0)
+	if step > 0 {
+		f.replace(pointer, "multipleOf", set, v, func() interface{} {
+			x, _ := strconv.ParseFloat(fmt.Sprint(v), 64)
+			return x + step/2
+		})
+	}
+
+	return v
+}
+
+// notIn returns a value which is not in the list.
+func (f *Faker) notIn(values []interface{}) interface{} {
+	for {
+		candidate := "near-miss-" + f.rand.String(6, randx.AlphaLower)
+		found := false
+		for _, v := range values {
+			if v == candidate {
+				found = true
+			}
+		}
+		if !found {
+			return candidate
+		}
+	}
+}
+
+// pattern generates a string which matches the regular expression. It
+// returns false if the regular expression is nil or no match was generated,
+// e.g. because it contains an empty character class.
+func (f *Faker) pattern(re *regexp.Regexp) (string, bool) {
+	if re == nil {
+		return "", false
+	}
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return "", false
+	}
+
+	var b strings.Builder
+	if !f.regexp(parsed.Simplify(), &b) {
+		return "", false
+	}
+	return b.String(), true
+}
+
+// regexp writes a string which matches the regular expression to b. It
+// returns false if the regular expression can not match.
+func (f *Faker) regexp(re *syntax.Regexp, b *strings.Builder) bool {
+	switch re.Op {
+	case syntax.OpNoMatch:
+		return false
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		r, ok := f.charClass(re.Rune)
+		if !ok {
+			return false
+		}
+		b.WriteRune(r)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteString(f.rand.String(1, randx.AlphaNum))
+	case syntax.OpCapture:
+		return f.regexp(re.Sub[0], b)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !f.regexp(sub, b) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		return f.regexp(re.Sub[f.rand.IntN(len(re.Sub))], b)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		minRepeat, maxRepeat := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			minRepeat, maxRepeat = 0, -1
+		case syntax.OpPlus:
+			minRepeat, maxRepeat = 1, -1
+		case syntax.OpQuest:
+			minRepeat, maxRepeat = 0, 1
+		}
+		if maxRepeat == -1 || maxRepeat > minRepeat+3 {
+			maxRepeat = minRepeat + 3
+		}
+		for n := f.rand.Int64Range(int64(minRepeat), int64(maxRepeat)); n > 0; n-- {
+			if !f.regexp(re.Sub[0], b) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// charClass returns a rune of the class, which is a list of inclusive
+// ranges. Printable ASCII runes are preferred. It returns false if the class
+// is empty, e.g. "[^\x00-\x{10FFFF}]".
+func (f *Faker) charClass(ranges []rune) (rune, bool) {
+	if len(ranges) < 2 {
+		return 0, false
+	}
+
+	var printable []rune
+	for k := 0; k+1 < len(ranges); k += 2 {
+		for r := max(ranges[k], ' '+1); r <= min(ranges[k+1], '~'); r++ {
+			printable = append(printable, r)
+		}
+	}
+	if len(printable) > 0 {
+		return printable[f.rand.IntN(len(printable))], true
+	}
+
+	k := 2 * f.rand.IntN(len(ranges)/2)
+	return rune(f.rand.Int64Range(int64(ranges[k]), int64(ranges[k+1]))), true
+}
+
+func escapePointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
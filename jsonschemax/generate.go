@@ -0,0 +1,538 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ory/jsonschema/v3"
+	"github.com/pkg/errors"
+)
+
+type (
+	// GeneratorOption configures GenerateGo and GenerateTypeScript.
+	GeneratorOption func(*generatorOptions)
+
+	generatorOptions struct {
+		packageName  string
+		rootType     string
+		maxRecursion uint8
+	}
+
+	// schemaNode is a node of the tree built from the paths of a schema.
+	// Nodes of properties without "type" have no path.
+	schemaNode struct {
+		path       *Path
+		name       string
+		segments   []string
+		children   map[string]*schemaNode
+		isRequired bool
+	}
+
+	generator struct {
+		o        *generatorOptions
+		names    map[*schemaNode]string
+		used     map[string]bool
+		objects  []*schemaNode
+		enums    []*schemaNode
+		timeUsed bool
+	}
+)
+
+// GeneratorWithPackageName sets the package name of generated Go code.
+// Defaults to "schema".
+func GeneratorWithPackageName(name string) GeneratorOption {
+	return func(o *generatorOptions) {
+		o.packageName = name
+	}
+}
+
+// GeneratorWithRootType sets the name of the type generated for the root of
+// the schema, which also prefixes the names of nested types. Defaults to
+// "Config".
+func GeneratorWithRootType(name string) GeneratorOption {
+	return func(o *generatorOptions) {
+		o.rootType = name
+	}
+}
+
+// GeneratorWithMaxRecursion sets how often circular references are followed.
+// Defaults to 5.
+func GeneratorWithMaxRecursion(depth uint8) GeneratorOption {
+	return func(o *generatorOptions) {
+		o.maxRecursion = depth
+	}
+}
+
+// GenerateGo generates Go structs for the schema. Optional fields are
+// pointers, and string and integer enums become named types with constants.
+// The result is gofmt-ed.
+func GenerateGo(ctx context.Context, ref string, compiler *jsonschema.Compiler, opts ...GeneratorOption) ([]byte, error) {
+	g, root, err := newGenerator(ctx, ref, compiler, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	for k := 0; k < len(g.objects); k++ {
+		n := g.objects[k]
+		writeComment(&body, "", n.path)
+		_, _ = fmt.Fprintf(&body, "type %s struct {\n", g.names[n])
+		fields := map[string]bool{}
+		for _, child := range n.sortedChildren() {
+			writeComment(&body, "\t", child.path)
+			tag := child.name
+			if !child.required() {
+				tag += ",omitempty"
+			}
+			_, _ = fmt.Fprintf(&body, "\t%s %s `json:%q`\n", unique(fields, goIdentifier(child.name)), g.goFieldType(child), tag)
+		}
+		body.WriteString("}\n\n")
+	}
+
+	for _, n := range g.enums {
+		name, base := g.names[n], g.goType(n, false)
+		writeComment(&body, "", n.path)
+		_, _ = fmt.Fprintf(&body, "type %s %s\n\nconst (\n", name, base)
+		for _, v := range n.path.Enum {
+			literal, err := json.Marshal(v)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			_, _ = fmt.Fprintf(&body, "\t%s %s = %s\n", unique(g.used, name+enumIdentifier(v)), name, literal)
+		}
+		body.WriteString(")\n\n")
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by jsonschemax. DO NOT EDIT.\n\n")
+	_, _ = fmt.Fprintf(&out, "package %s\n\n", g.o.packageName)
+	if g.timeUsed {
+		out.WriteString("import \"time\"\n\n")
+	}
+	out.Write(body.Bytes())
+
+	formatted, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to format the Go code generated for %s", g.names[root])
+	}
+	return formatted, nil
+}
+
+// GenerateTypeScript generates TypeScript interfaces for the schema. Optional
+// properties are marked with "?", read-only properties are readonly, and
+// enums become union types.
+func GenerateTypeScript(ctx context.Context, ref string, compiler *jsonschema.Compiler, opts ...GeneratorOption) ([]byte, error) {
+	g, _, err := newGenerator(ctx, ref, compiler, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by jsonschemax. DO NOT EDIT.\n\n")
+	for k := 0; k < len(g.objects); k++ {
+		n := g.objects[k]
+		writeJSDoc(&out, "", n.path)
+		_, _ = fmt.Fprintf(&out, "export interface %s {\n", g.names[n])
+		for _, child := range n.sortedChildren() {
+			writeJSDoc(&out, "  ", child.path)
+			name := child.name
+			if !isTypeScriptIdentifier(name) {
+				name = strconv.Quote(name)
+			}
+			if child.path != nil && child.path.ReadOnly {
+				name = "readonly " + name
+			}
+			if !child.required() {
+				name += "?"
+			}
+			_, _ = fmt.Fprintf(&out, "  %s: %s\n", name, g.tsType(child))
+		}
+		out.WriteString("}\n\n")
+	}
+
+	for _, n := range g.enums {
+		values := make([]string, len(n.path.Enum))
+		for k, v := range n.path.Enum {
+			literal, err := json.Marshal(v)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			values[k] = string(literal)
+		}
+		writeJSDoc(&out, "", n.path)
+		_, _ = fmt.Fprintf(&out, "export type %s = %s\n\n", g.names[n], strings.Join(values, " | "))
+	}
+
+	return append(bytes.TrimRight(out.Bytes(), "\n"), '\n'), nil
+}
+
+func newGenerator(ctx context.Context, ref string, compiler *jsonschema.Compiler, opts []GeneratorOption) (*generator, *schemaNode, error) {
+	o := &generatorOptions{packageName: "schema", rootType: "Config", maxRecursion: 5}
+	for _, f := range opts {
+		f(o)
+	}
+
+	if compiler == nil {
+		compiler = jsonschema.NewCompiler()
+	}
+	compiler.ExtractAnnotations = true
+	schema, err := compiler.Compile(ctx, ref)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	paths, err := runPaths(schema, int16(o.maxRecursion), true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := &schemaNode{children: map[string]*schemaNode{}}
+	for k := range paths {
+		root.node(strings.Split(paths[k].Name, ".")).path = &paths[k]
+	}
+	// Properties without "type" have no path, but are fields nonetheless.
+	walkProperties(schema, nil, map[*jsonschema.Schema]int{}, int(o.maxRecursion), func(segments []string, required bool) {
+		root.node(segments).isRequired = required
+	})
+
+	g := &generator{
+		o:     o,
+		names: map[*schemaNode]string{},
+		used:  map[string]bool{},
+	}
+	g.names[root] = o.rootType
+	g.used[o.rootType] = true
+	g.objects = append(g.objects, root)
+	g.collect(root)
+	return g, root, nil
+}
+
+// collect names the objects and enums of the tree in breadth-first order.
+func (g *generator) collect(root *schemaNode) {
+	queue := []*schemaNode{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, child := range n.sortedChildren() {
+			if child.isObject() || child.isEnum() {
+				name := g.o.rootType
+				for _, segment := range child.segments {
+					if segment == "#" {
+						segment = "item"
+					}
+					name += goIdentifier(segment)
+				}
+				g.names[child] = unique(g.used, name)
+
+				if child.isObject() {
+					g.objects = append(g.objects, child)
+				} else {
+					g.enums = append(g.enums, child)
+				}
+			}
+			queue = append(queue, child)
+		}
+	}
+}
+
+// unique returns the name, or the name with the smallest numeric suffix which
+// is not used yet, so that names which normalize to the same identifier, such
+// as "a_b" and "a-b", do not collide. The returned name is marked as used.
+func unique(used map[string]bool, name string) string {
+	for k, base := 2, name; used[name]; k++ {
+		name = base + strconv.Itoa(k)
+	}
+	used[name] = true
+	return name
+}
+
+// node returns the descendant at the segments, adding missing nodes.
+func (n *schemaNode) node(segments []string) *schemaNode {
+	for _, segment := range segments {
+		child, ok := n.children[segment]
+		if !ok {
+			child = &schemaNode{
+				name:     segment,
+				segments: append(append([]string{}, n.segments...), segment),
+				children: map[string]*schemaNode{},
+			}
+			n.children[segment] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// walkProperties calls fn with the segments of every property and array item
+// of the schema, including those without "type". References are followed at
+// most maxRecursion times.
+func walkProperties(schema *jsonschema.Schema, parents []string, visited map[*jsonschema.Schema]int, maxRecursion int, fn func(segments []string, required bool)) {
+	if visited[schema] > maxRecursion {
+		return
+	}
+	visited[schema]++
+	defer func() { visited[schema]-- }()
+
+	subs := append(append(append([]*jsonschema.Schema{schema.Ref, schema.If, schema.Then, schema.Else}, schema.AllOf...), schema.AnyOf...), schema.OneOf...)
+	for _, sub := range subs {
+		if sub != nil {
+			walkProperties(sub, parents, visited, maxRecursion, fn)
+		}
+	}
+
+	for name, sub := range schema.Properties {
+		segments := append(append([]string{}, parents...), name)
+		fn(segments, slices.Contains(schema.Required, name))
+		walkProperties(sub, segments, visited, maxRecursion, fn)
+	}
+
+	var items []*jsonschema.Schema
+	switch t := schema.Items.(type) {
+	case []*jsonschema.Schema:
+		items = t
+	case *jsonschema.Schema:
+		items = []*jsonschema.Schema{t}
+	}
+	for _, sub := range items {
+		walkProperties(sub, append(append([]string{}, parents...), "#"), visited, maxRecursion, fn)
+	}
+}
+
+func (n *schemaNode) sortedChildren() []*schemaNode {
+	children := make([]*schemaNode, 0, len(n.children))
+	for _, child := range n.children {
+		if child.name != "#" {
+			children = append(children, child)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	if items, ok := n.children["#"]; ok {
+		children = append(children, items)
+	}
+	return children
+}
+
+func (n *schemaNode) required() bool {
+	return n.isRequired || (n.path != nil && n.path.Required)
+}
+
+func (n *schemaNode) items() *schemaNode {
+	return n.children["#"]
+}
+
+func (n *schemaNode) isObject() bool {
+	return len(n.children) > 0 && n.items() == nil
+}
+
+func (n *schemaNode) isEnum() bool {
+	if n.path == nil || len(n.path.Enum) == 0 || len(n.children) > 0 {
+		return false
+	}
+	switch n.path.TypeHint {
+	case String, Int, Float:
+		return true
+	}
+	return false
+}
+
+// goFieldType returns the Go type of a struct field, which is a pointer for
+// optional fields unless its zero value is nil.
+func (g *generator) goFieldType(n *schemaNode) string {
+	t := g.goType(n, true)
+	if n.required() || strings.HasPrefix(t, "[]") || strings.HasPrefix(t, "map[") || t == "interface{}" {
+		return t
+	}
+	return "*" + t
+}
+
+func (g *generator) goType(n *schemaNode, named bool) string {
+	if name, ok := g.names[n]; ok && (named || n.isObject()) {
+		return name
+	}
+	if items := n.items(); items != nil {
+		return "[]" + g.goType(items, true)
+	}
+	if n.path == nil {
+		return "interface{}"
+	}
+
+	switch n.path.Type.(type) {
+	case string:
+		if n.path.Format == "date-time" {
+			g.timeUsed = true
+			return "time.Time"
+		}
+		return "string"
+	case float64:
+		if n.path.TypeHint == Int {
+			return "int64"
+		}
+		return "float64"
+	case bool:
+		return "bool"
+	case []string:
+		return "[]string"
+	case []bool:
+		return "[]bool"
+	case []float64:
+		if n.path.TypeHint == IntSlice {
+			return "[]int64"
+		}
+		return "[]float64"
+	case []interface{}:
+		return "[]interface{}"
+	case map[string]interface{}:
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+func (g *generator) tsType(n *schemaNode) string {
+	if name, ok := g.names[n]; ok {
+		return name
+	}
+	if items := n.items(); items != nil {
+		t := g.tsType(items)
+		if strings.Contains(t, " ") {
+			t = "(" + t + ")"
+		}
+		return t + "[]"
+	}
+	if n.path == nil {
+		return "unknown"
+	}
+
+	switch n.path.Type.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []string:
+		return "string[]"
+	case []bool:
+		return "boolean[]"
+	case []float64:
+		return "number[]"
+	case []interface{}:
+		return "unknown[]"
+	case map[string]interface{}:
+		return "Record<string, unknown>"
+	}
+	return "unknown"
+}
+
+// commonInitialisms are spelled in upper case in Go identifiers.
+var commonInitialisms = map[string]bool{
+	"API": true, "CORS": true, "CPU": true, "DB": true, "DNS": true, "DSN": true, "HTML": true, "HTTP": true,
+	"HTTPS": true, "ID": true, "IP": true, "JSON": true, "JWK": true, "JWKS": true, "JWT": true, "OIDC": true,
+	"SMTP": true, "SQL": true, "SSL": true, "TLS": true, "TTL": true, "UI": true, "URI": true, "URL": true,
+	"UUID": true,
+}
+
+// goIdentifier converts the name to an exported Go identifier, e.g.
+// "client_id" to "ClientID".
+func goIdentifier(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+
+	identifier := b.String()
+	if identifier == "" {
+		return "Empty"
+	} else if unicode.IsDigit([]rune(identifier)[0]) {
+		return "V" + identifier
+	}
+	return identifier
+}
+
+// enumIdentifier converts the enum value to the suffix of its constant. The
+// decimal point and sign of numbers are kept, e.g. 1.5 becomes "V1_5" and
+// -1 becomes "Minus1", so that they do not collide with 15 and 1.
+func enumIdentifier(v interface{}) string {
+	var number float64
+	switch n := v.(type) {
+	case float64:
+		number = n
+	case json.Number:
+		f, err := n.Float64()
+		if err != nil {
+			return goIdentifier(n.String())
+		}
+		number = f
+	default:
+		return goIdentifier(fmt.Sprint(v))
+	}
+
+	identifier := strings.ReplaceAll(strconv.FormatFloat(math.Abs(number), 'f', -1, 64), ".", "_")
+	if number < 0 {
+		return "Minus" + identifier
+	}
+	return "V" + identifier
+}
+
+func isTypeScriptIdentifier(name string) bool {
+	for k, r := range name {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || (k > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return name != ""
+}
+
+func pathDoc(p *Path) []string {
+	if p == nil {
+		return nil
+	}
+
+	var lines []string
+	if p.Title != "" {
+		lines = append(lines, p.Title)
+	}
+	if p.Description != "" {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+		lines = append(lines, strings.Split(strings.TrimSpace(p.Description), "\n")...)
+	}
+	return lines
+}
+
+func writeComment(b *bytes.Buffer, indent string, p *Path) {
+	for _, line := range pathDoc(p) {
+		b.WriteString(strings.TrimRight(indent+"// "+line, " ") + "\n")
+	}
+}
+
+func writeJSDoc(b *bytes.Buffer, indent string, p *Path) {
+	lines := pathDoc(p)
+	if len(lines) == 0 {
+		return
+	}
+
+	b.WriteString(indent + "/**\n")
+	for _, line := range lines {
+		b.WriteString(strings.TrimRight(indent+" * "+strings.ReplaceAll(line, "*/", "*\\/"), " ") + "\n")
+	}
+	b.WriteString(indent + " */\n")
+}
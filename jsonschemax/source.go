@@ -0,0 +1,456 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/ory/jsonschema/v3"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+type (
+	// SourceError is a validation error mapped to its position in the
+	// source file. Line and Column are 1-based and zero if the position is
+	// unknown.
+	SourceError struct {
+		File    string `json:"file"`
+		Line    int    `json:"line"`
+		Column  int    `json:"column"`
+		Pointer string `json:"pointer"`
+		Path    string `json:"path"`
+		Keyword string `json:"keyword,omitempty"`
+		Message string `json:"message"`
+	}
+
+	sourcePosition struct {
+		line, column int
+	}
+)
+
+// NewSourceErrors maps the causes of a *jsonschema.ValidationError to their
+// positions in the JSON, YAML, or TOML source. The format is detected from
+// the file extension. Values which do not exist in the source, e.g. missing
+// required properties, are mapped to the closest parent. Other errors are
+// returned as a single SourceError without position.
+func NewSourceErrors(file string, source []byte, err error) []SourceError {
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []SourceError{{File: file, Message: err.Error()}}
+	}
+
+	positions := sourcePositions(file, source)
+	var errs []SourceError
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				collect(cause)
+			}
+			return
+		}
+
+		keyword := e.SchemaPtr[strings.LastIndex(e.SchemaPtr, "/")+1:]
+		add := func(pointer, message string) {
+			pointer = strings.TrimPrefix(pointer, "#")
+			se := SourceError{File: file, Pointer: pointer, Keyword: keyword, Message: message}
+			if path, err := JSONPointerToDotNotation("#" + pointer); err == nil {
+				se.Path = path
+			}
+			for p := pointer; ; p = p[:strings.LastIndex(p, "/")] {
+				if pos, ok := positions[p]; ok {
+					se.Line, se.Column = pos.line, pos.column
+					break
+				} else if p == "" {
+					break
+				}
+			}
+			errs = append(errs, se)
+		}
+
+		if required, ok := e.Context.(*jsonschema.ValidationErrorContextRequired); ok && len(required.Missing) > 0 {
+			for _, missing := range required.Missing {
+				add(missing, fmt.Sprintf("property %s is missing", missing[strings.LastIndex(missing, "/")+1:]))
+			}
+			return
+		}
+		add(e.InstancePtr, e.Message)
+	}
+	collect(ve)
+	return errs
+}
+
+// FormatSourceErrorsForCLI prints the errors with an excerpt of the source
+// line and a caret pointing at the offending value.
+func FormatSourceErrorsForCLI(w io.Writer, source []byte, errs []SourceError) {
+	lines := strings.Split(string(source), "\n")
+	for _, e := range errs {
+		location := e.File
+		if e.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+		}
+		path := e.Path
+		if path == "" {
+			path = "(root)"
+		}
+		_, _ = fmt.Fprintf(w, "%s: %s: %s\n", location, path, e.Message)
+
+		if e.Line > 0 && e.Line <= len(lines) {
+			line := strings.TrimRight(lines[e.Line-1], "\r")
+			gutter := strconv.Itoa(e.Line)
+			_, _ = fmt.Fprintf(w, " %s | %s\n", gutter, line)
+
+			// Keep tabs so that the caret lines up with the excerpt.
+			var indent strings.Builder
+			for k, r := range []rune(line) {
+				if k >= e.Column-1 {
+					break
+				}
+				if r == '\t' {
+					indent.WriteRune('\t')
+				} else {
+					indent.WriteRune(' ')
+				}
+			}
+			_, _ = fmt.Fprintf(w, " %s | %s^\n", strings.Repeat(" ", len(gutter)), indent.String())
+		}
+		_, _ = fmt.Fprintln(w)
+	}
+}
+
+// WriteSourceErrorsJSON writes the errors as a JSON list.
+func WriteSourceErrorsJSON(w io.Writer, errs []SourceError) error {
+	if errs == nil {
+		errs = []SourceError{}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.WithStack(e.Encode(errs))
+}
+
+// WriteSourceErrorsSARIF writes the errors as a SARIF 2.1.0 log, which code
+// scanning tools and editors can show inline. The JSON Schema keywords are
+// used as rule IDs.
+func WriteSourceErrorsSARIF(w io.Writer, toolName string, errs []SourceError) error {
+	type (
+		sarifMessage struct {
+			Text string `json:"text"`
+		}
+		sarifRegion struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn,omitempty"`
+		}
+		sarifArtifactLocation struct {
+			URI string `json:"uri"`
+		}
+		sarifPhysicalLocation struct {
+			ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+			Region           *sarifRegion          `json:"region,omitempty"`
+		}
+		sarifLocation struct {
+			PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		}
+		sarifResult struct {
+			RuleID    string          `json:"ruleId"`
+			Level     string          `json:"level"`
+			Message   sarifMessage    `json:"message"`
+			Locations []sarifLocation `json:"locations"`
+		}
+		sarifRule struct {
+			ID string `json:"id"`
+		}
+		sarifDriver struct {
+			Name  string      `json:"name"`
+			Rules []sarifRule `json:"rules"`
+		}
+		sarifTool struct {
+			Driver sarifDriver `json:"driver"`
+		}
+		sarifRun struct {
+			Tool    sarifTool     `json:"tool"`
+			Results []sarifResult `json:"results"`
+		}
+		sarifLog struct {
+			Schema  string     `json:"$schema"`
+			Version string     `json:"version"`
+			Runs    []sarifRun `json:"runs"`
+		}
+	)
+
+	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: toolName, Rules: []sarifRule{}}}, Results: []sarifResult{}}
+	rules := map[string]bool{}
+	for _, e := range errs {
+		ruleID := e.Keyword
+		if ruleID == "" {
+			ruleID = "invalid"
+		}
+		if !rules[ruleID] {
+			rules[ruleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: ruleID})
+		}
+
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(e.File)}}
+		if e.Line > 0 {
+			location.Region = &sarifRegion{StartLine: e.Line, StartColumn: e.Column}
+		}
+
+		message := e.Message
+		if e.Path != "" {
+			message = e.Path + ": " + message
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    ruleID,
+			Level:     "error",
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return errors.WithStack(enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}))
+}
+
+// sourcePositions returns the positions of the values in the source keyed by
+// JSON pointer. Object members are positioned at their key. Unparsable
+// sources have no positions.
+func sourcePositions(file string, source []byte) map[string]sourcePosition {
+	positions := map[string]sourcePosition{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		jsonPositions(source, positions)
+	case ".yaml", ".yml":
+		yamlPositions(source, positions)
+	case ".toml":
+		tomlPositions(source, positions)
+	default:
+		if trimmed := bytes.TrimSpace(source); len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			jsonPositions(source, positions)
+		} else {
+			yamlPositions(source, positions)
+		}
+	}
+	return positions
+}
+
+func yamlPositions(source []byte, positions map[string]sourcePosition) {
+	f, err := parser.ParseBytes(source, 0)
+	if err != nil || len(f.Docs) == 0 {
+		return
+	}
+
+	var walk func(n ast.Node, pointer string)
+	walk = func(n ast.Node, pointer string) {
+		switch n := n.(type) {
+		case *ast.TagNode:
+			walk(n.Value, pointer)
+		case *ast.AnchorNode:
+			walk(n.Value, pointer)
+		case *ast.MappingNode:
+			for _, value := range n.Values {
+				walk(value, pointer)
+			}
+		case *ast.MappingValueNode:
+			key := n.Key.GetToken()
+			member := pointer + "/" + escapePointer(key.Value)
+			positions[member] = sourcePosition{line: key.Position.Line, column: key.Position.Column}
+			walk(n.Value, member)
+		case *ast.SequenceNode:
+			for k, value := range n.Values {
+				element := pointer + "/" + strconv.Itoa(k)
+				if t := value.GetToken(); t != nil {
+					positions[element] = sourcePosition{line: t.Position.Line, column: t.Position.Column}
+				}
+				walk(value, element)
+			}
+		}
+	}
+
+	body := f.Docs[0].Body
+	if body == nil {
+		return
+	}
+	if t := body.GetToken(); t != nil {
+		positions[""] = sourcePosition{line: t.Position.Line, column: t.Position.Column}
+	}
+	walk(body, "")
+}
+
+func tomlPositions(source []byte, positions map[string]sourcePosition) {
+	tree, err := toml.LoadBytes(source)
+	if err != nil {
+		return
+	}
+
+	positions[""] = sourcePosition{line: 1, column: 1}
+	var walk func(t *toml.Tree, pointer string)
+	walk = func(t *toml.Tree, pointer string) {
+		for _, key := range t.Keys() {
+			member := pointer + "/" + escapePointer(key)
+			if pos := t.GetPositionPath([]string{key}); !pos.Invalid() {
+				positions[member] = sourcePosition{line: pos.Line, column: pos.Col}
+			}
+
+			switch v := t.GetPath([]string{key}).(type) {
+			case *toml.Tree:
+				walk(v, member)
+			case []*toml.Tree:
+				for k, sub := range v {
+					element := member + "/" + strconv.Itoa(k)
+					if pos := sub.Position(); !pos.Invalid() {
+						positions[element] = sourcePosition{line: pos.Line, column: pos.Col}
+					}
+					walk(sub, element)
+				}
+			}
+		}
+	}
+	walk(tree, "")
+}
+
+// jsonPositions scans the JSON source. It stops at the first syntax error.
+func jsonPositions(source []byte, positions map[string]sourcePosition) {
+	s := &jsonScanner{source: source, positions: positions, lineStarts: []int{0}}
+	for k, c := range source {
+		if c == '\n' {
+			s.lineStarts = append(s.lineStarts, k+1)
+		}
+	}
+	s.whitespace()
+	s.record("")
+	_ = s.value("")
+}
+
+type jsonScanner struct {
+	source     []byte
+	offset     int
+	lineStarts []int
+	positions  map[string]sourcePosition
+}
+
+var errJSONSyntax = errors.New("invalid JSON")
+
+func (s *jsonScanner) record(pointer string) {
+	line := sort.SearchInts(s.lineStarts, s.offset+1)
+	s.positions[pointer] = sourcePosition{line: line, column: utf8.RuneCount(s.source[s.lineStarts[line-1]:s.offset]) + 1}
+}
+
+func (s *jsonScanner) whitespace() {
+	for s.offset < len(s.source) && strings.IndexByte(" \t\r\n", s.source[s.offset]) >= 0 {
+		s.offset++
+	}
+}
+
+func (s *jsonScanner) consume(c byte) bool {
+	s.whitespace()
+	if s.offset < len(s.source) && s.source[s.offset] == c {
+		s.offset++
+		return true
+	}
+	return false
+}
+
+func (s *jsonScanner) string() (string, error) {
+	start := s.offset
+	for s.offset++; s.offset < len(s.source); s.offset++ {
+		switch s.source[s.offset] {
+		case '\\':
+			s.offset++
+		case '"':
+			s.offset++
+			var v string
+			if err := json.Unmarshal(s.source[start:s.offset], &v); err != nil {
+				return "", errors.WithStack(errJSONSyntax)
+			}
+			return v, nil
+		}
+	}
+	return "", errors.WithStack(errJSONSyntax)
+}
+
+func (s *jsonScanner) value(pointer string) error {
+	s.whitespace()
+	if s.offset >= len(s.source) {
+		return errors.WithStack(errJSONSyntax)
+	}
+
+	switch s.source[s.offset] {
+	case '{':
+		s.offset++
+		if s.consume('}') {
+			return nil
+		}
+		for {
+			s.whitespace()
+			if s.offset >= len(s.source) || s.source[s.offset] != '"' {
+				return errors.WithStack(errJSONSyntax)
+			}
+			keyOffset := s.offset
+			key, err := s.string()
+			if err != nil {
+				return err
+			}
+
+			member := pointer + "/" + escapePointer(key)
+			end := s.offset
+			s.offset = keyOffset
+			s.record(member)
+			s.offset = end
+
+			if !s.consume(':') {
+				return errors.WithStack(errJSONSyntax)
+			}
+			if err := s.value(member); err != nil {
+				return err
+			}
+			if s.consume('}') {
+				return nil
+			} else if !s.consume(',') {
+				return errors.WithStack(errJSONSyntax)
+			}
+		}
+	case '[':
+		s.offset++
+		if s.consume(']') {
+			return nil
+		}
+		for k := 0; ; k++ {
+			s.whitespace()
+			element := pointer + "/" + strconv.Itoa(k)
+			s.record(element)
+			if err := s.value(element); err != nil {
+				return err
+			}
+			if s.consume(']') {
+				return nil
+			} else if !s.consume(',') {
+				return errors.WithStack(errJSONSyntax)
+			}
+		}
+	case '"':
+		_, err := s.string()
+		return err
+	default:
+		for s.offset < len(s.source) && strings.IndexByte(",}] \t\r\n", s.source[s.offset]) < 0 {
+			s.offset++
+		}
+		return nil
+	}
+}
@@ -0,0 +1,273 @@
+package jsonx
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNotCanonicalizable is returned for values which have no canonical JSON
+// representation, e.g. numbers which are out of the IEEE 754 double range or
+// objects with duplicate keys.
+var ErrNotCanonicalizable = errors.New("value can not be canonicalized")
+
+// Canonicalize returns the RFC 8785 JSON Canonicalization Scheme (JCS) form
+// of the JSON document: object keys are sorted by their UTF-16 code units,
+// insignificant whitespace is removed, and strings and numbers are serialized
+// like ECMAScript does. Equal documents have the same canonical form, so it
+// can be hashed or signed.
+//
+// As JCS requires I-JSON input, numbers are treated as IEEE 754 doubles and
+// integers beyond 2^53 lose precision, and objects with duplicate keys are
+// rejected.
+func Canonicalize(data []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	v, err := decodeCanonical(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+
+	var b bytes.Buffer
+	if err := canonicalize(&b, v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// CanonicalMarshal encodes the value with encoding/json and returns its RFC
+// 8785 canonical form. Pass encoded documents as json.RawMessage, as []byte is
+// encoded as a base64 string.
+func CanonicalMarshal(v interface{}) ([]byte, error) {
+	raw, err := marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return Canonicalize(raw)
+}
+
+// CanonicalDigest returns the digest of the canonical form of the value,
+// computed with the hash function.
+func CanonicalDigest(v interface{}, newHash func() hash.Hash) ([]byte, error) {
+	canonical, err := CanonicalMarshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	h := newHash()
+	_, _ = h.Write(canonical)
+	return h.Sum(nil), nil
+}
+
+// CanonicalSHA256 returns the hex encoded SHA-256 digest of the canonical form
+// of the value. It is stable across key order, whitespace, and number
+// formatting, which makes it suitable for cache keys and ETags.
+func CanonicalSHA256(v interface{}) (string, error) {
+	digest, err := CanonicalDigest(v, sha256.New)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(digest), nil
+}
+
+// decodeCanonical decodes the next JSON value like encoding/json does, but
+// rejects objects with duplicate keys.
+func decodeCanonical(d *json.Decoder) (interface{}, error) {
+	t, err := d.Token()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch t {
+	case json.Delim('{'):
+		obj := map[string]interface{}{}
+		for d.More() {
+			t, err := d.Token()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			key := t.(string)
+			if _, ok := obj[key]; ok {
+				return nil, errors.Wrapf(ErrNotCanonicalizable, "duplicate key %q", key)
+			}
+			if obj[key], err = decodeCanonical(d); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return obj, nil
+	case json.Delim('['):
+		arr := []interface{}{}
+		for d.More() {
+			v, err := decodeCanonical(d)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return arr, nil
+	}
+	return t, nil
+}
+
+func canonicalize(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case string:
+		canonicalString(b, v)
+	case json.Number:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		if err != nil {
+			return errors.Wrapf(ErrNotCanonicalizable, "number %s: %s", v, err)
+		}
+		n, err := canonicalNumber(f)
+		if err != nil {
+			return err
+		}
+		b.WriteString(n)
+	case []interface{}:
+		b.WriteByte('[')
+		for k, e := range v {
+			if k > 0 {
+				b.WriteByte(',')
+			}
+			if err := canonicalize(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool { return lessUTF16(keys[i], keys[j]) })
+
+		b.WriteByte('{')
+		for k, key := range keys {
+			if k > 0 {
+				b.WriteByte(',')
+			}
+			canonicalString(b, key)
+			b.WriteByte(':')
+			if err := canonicalize(b, v[key]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return errors.Wrapf(ErrNotCanonicalizable, "unexpected type %T", v)
+	}
+	return nil
+}
+
+// lessUTF16 compares the strings by their UTF-16 code units.
+func lessUTF16(a, b string) bool {
+	ea, eb := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for k := 0; k < len(ea) && k < len(eb); k++ {
+		if ea[k] != eb[k] {
+			return ea[k] < eb[k]
+		}
+	}
+	return len(ea) < len(eb)
+}
+
+func canonicalString(b *bytes.Buffer, s string) {
+	const hexDigits = "0123456789abcdef"
+
+	b.WriteByte('"')
+	for len(s) > 0 {
+		r, size := utf8.DecodeRuneInString(s)
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\b':
+			b.WriteString(`\b`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20:
+			b.WriteString(`\u00`)
+			b.WriteByte(hexDigits[r>>4])
+			b.WriteByte(hexDigits[r&0xf])
+		default:
+			b.WriteString(s[:size])
+		}
+		s = s[size:]
+	}
+	b.WriteByte('"')
+}
+
+// canonicalNumber serializes the number like ECMAScript's Number.toString.
+func canonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", errors.Wrapf(ErrNotCanonicalizable, "number %v", f)
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	var sign string
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// The shortest representation which round-trips, e.g. "1.2345e+02".
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exponent, _ := strings.Cut(e, "e")
+	digits := strings.Replace(mantissa, ".", "", 1)
+	exp, err := strconv.Atoi(exponent)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	// ECMAScript: the value is digits × 10^(n-k).
+	k, n := len(digits), exp+1
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	expSign := "+"
+	if n-1 < 0 {
+		expSign = "-"
+	}
+	expValue := strconv.Itoa(int(math.Abs(float64(n - 1))))
+	if k == 1 {
+		return sign + digits + "e" + expSign + expValue, nil
+	}
+	return sign + digits[:1] + "." + digits[1:] + "e" + expSign + expValue, nil
+}
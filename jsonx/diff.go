@@ -0,0 +1,318 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference is a change between two JSON documents.
+type Difference struct {
+	// Op is "add", "remove", or "replace".
+	Op string `json:"op"`
+	// Pointer is the RFC 6901 JSON Pointer of the changed value.
+	Pointer string `json:"path"`
+	// Old is the value in the original document. It is nil for "add".
+	Old json.RawMessage `json:"old,omitempty"`
+	// New is the value in the modified document. It is nil for "remove".
+	New json.RawMessage `json:"new,omitempty"`
+}
+
+// Diff returns the structural differences between the original and the
+// modified document. Objects are compared key by key and arrays index by
+// index, so an insertion in the middle of an array is reported as
+// replacements of the following elements. Numbers are compared by value.
+//
+// Differences are ordered such that applying them in order as a JSON Patch
+// turns the original into the modified document.
+func Diff(original, modified []byte) ([]Difference, error) {
+	var o, m interface{}
+	if err := unmarshal(original, &o); err != nil {
+		return nil, err
+	}
+	if err := unmarshal(modified, &m); err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	if err := diff(o, m, "", &diffs); err != nil {
+		return nil, err
+	}
+	return diffs, nil
+}
+
+// DiffPointers returns the JSON Pointers of the values which differ between
+// the original and the modified document.
+func DiffPointers(original, modified []byte) ([]string, error) {
+	diffs, err := Diff(original, modified)
+	if err != nil {
+		return nil, err
+	}
+
+	pointers := make([]string, len(diffs))
+	for k, d := range diffs {
+		pointers[k] = d.Pointer
+	}
+	return pointers, nil
+}
+
+// CreateJSONPatch returns the RFC 6902 JSON Patch which turns the original
+// into the modified document.
+func CreateJSONPatch(original, modified []byte) ([]byte, error) {
+	diffs, err := Diff(original, modified)
+	if err != nil {
+		return nil, err
+	}
+
+	ops := make([]PatchOperation, len(diffs))
+	for k, d := range diffs {
+		ops[k] = PatchOperation{Op: d.Op, Path: d.Pointer, Value: d.New}
+	}
+	return marshal(ops)
+}
+
+// CreateMergePatch returns the RFC 7396 JSON Merge Patch which turns the
+// original into the modified document. Merge patches can not express null
+// values in objects, or changes inside arrays, which replace the whole array.
+func CreateMergePatch(original, modified []byte) ([]byte, error) {
+	var o, m interface{}
+	if err := unmarshal(original, &o); err != nil {
+		return nil, err
+	}
+	if err := unmarshal(modified, &m); err != nil {
+		return nil, err
+	}
+	return marshal(mergeDiff(o, m))
+}
+
+func mergeDiff(original, modified interface{}) interface{} {
+	o, ok := original.(map[string]interface{})
+	m, ok2 := modified.(map[string]interface{})
+	if !ok || !ok2 {
+		return modified
+	}
+
+	patch := map[string]interface{}{}
+	for k := range o {
+		if _, ok := m[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, v := range m {
+		ov, ok := o[k]
+		if !ok {
+			patch[k] = v
+		} else if !equal(ov, v) {
+			patch[k] = mergeDiff(ov, v)
+		}
+	}
+	return patch
+}
+
+func diff(original, modified interface{}, pointer string, diffs *[]Difference) error {
+	add := func(op, pointer string, o, m interface{}) error {
+		d := Difference{Op: op, Pointer: pointer}
+		var err error
+		if op != "add" {
+			if d.Old, err = marshal(o); err != nil {
+				return err
+			}
+		}
+		if op != "remove" {
+			if d.New, err = marshal(m); err != nil {
+				return err
+			}
+		}
+		*diffs = append(*diffs, d)
+		return nil
+	}
+
+	switch o := original.(type) {
+	case map[string]interface{}:
+		m, ok := modified.(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		keys := make([]string, 0, len(o)+len(m))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		for k := range m {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			child := pointer + "/" + escapePointerToken(k)
+			ov, inOriginal := o[k]
+			mv, inModified := m[k]
+			var err error
+			switch {
+			case !inModified:
+				err = add("remove", child, ov, nil)
+			case !inOriginal:
+				err = add("add", child, nil, mv)
+			default:
+				err = diff(ov, mv, child, diffs)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		m, ok := modified.([]interface{})
+		if !ok {
+			break
+		}
+
+		for i := 0; i < len(o) && i < len(m); i++ {
+			if err := diff(o[i], m[i], pointer+"/"+strconv.Itoa(i), diffs); err != nil {
+				return err
+			}
+		}
+		// Remove from the end so that the indices stay valid.
+		for i := len(o) - 1; i >= len(m); i-- {
+			if err := add("remove", pointer+"/"+strconv.Itoa(i), o[i], nil); err != nil {
+				return err
+			}
+		}
+		for i := len(o); i < len(m); i++ {
+			if err := add("add", pointer+"/"+strconv.Itoa(i), nil, m[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if !equal(original, modified) {
+		return add("replace", pointer, original, modified)
+	}
+	return nil
+}
+
+// equal compares JSON values decoded with json.Number.
+func equal(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			bv, ok := b[k]
+			if !ok || !equal(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k := range a {
+			if !equal(a[k], b[k]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okA := new(big.Float).SetString(a.String())
+		y, okB := new(big.Float).SetString(b.String())
+		if !okA || !okB {
+			return a == b
+		}
+		return x.Cmp(y) == 0
+	}
+	return a == b
+}
+
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// FormatDiff renders the differences one per line, which is easy to read in
+// test failures:
+//
+//	~ /name: "Foo" => "Bar"
+//	- /email: "foo@example.com"
+//	+ /tags/1: "admin"
+func FormatDiff(diffs []Difference) string {
+	var b strings.Builder
+	for _, d := range diffs {
+		switch d.Op {
+		case "add":
+			_, _ = fmt.Fprintf(&b, "+ %s: %s\n", d.Pointer, compact(d.New))
+		case "remove":
+			_, _ = fmt.Fprintf(&b, "- %s: %s\n", d.Pointer, compact(d.Old))
+		default:
+			_, _ = fmt.Fprintf(&b, "~ %s: %s => %s\n", d.Pointer, compact(d.Old), compact(d.New))
+		}
+	}
+	return b.String()
+}
+
+// PrettyDiff returns FormatDiff of the differences between the documents, or
+// an empty string if they are equal. Values of the sensitive keys are masked
+// as with MaskKeys, but changes of them are still shown.
+func PrettyDiff(original, modified []byte, sensitiveKeys ...string) (string, error) {
+	diffs, err := Diff(original, modified)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sensitiveKeys) > 0 {
+		keys := sensitiveKeySet(sensitiveKeys)
+		for k, d := range diffs {
+			if diffs[k].Old, err = maskDifference(d.Pointer, d.Old, keys); err != nil {
+				return "", err
+			}
+			if diffs[k].New, err = maskDifference(d.Pointer, d.New, keys); err != nil {
+				return "", err
+			}
+		}
+	}
+	return FormatDiff(diffs), nil
+}
+
+func maskDifference(pointer string, raw json.RawMessage, keys map[string]bool) (json.RawMessage, error) {
+	if raw == nil {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		if keys[strings.ToLower(token)] {
+			return marshal(maskValue(v))
+		}
+	}
+	return marshal(maskKeys(v, keys))
+}
+
+func compact(raw json.RawMessage) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return string(raw)
+	}
+	return b.String()
+}
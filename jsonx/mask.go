@@ -0,0 +1,62 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// MaskKeys replaces the values of sensitive keys with a string indicating
+// their type, like Anonymize does. Keys are matched case-insensitively at any
+// depth. Objects and arrays under a sensitive key keep their shape, but all
+// of their values are replaced.
+func MaskKeys(data []byte, keys ...string) ([]byte, error) {
+	var v interface{}
+	if err := unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return marshal(maskKeys(v, sensitiveKeySet(keys)))
+}
+
+func sensitiveKeySet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		set[strings.ToLower(k)] = true
+	}
+	return set
+}
+
+func maskKeys(v interface{}, keys map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			if keys[strings.ToLower(k)] {
+				v[k] = maskValue(value)
+			} else {
+				v[k] = maskKeys(value, keys)
+			}
+		}
+	case []interface{}:
+		for k, value := range v {
+			v[k] = maskKeys(value, keys)
+		}
+	}
+	return v
+}
+
+func maskValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, value := range v {
+			v[k] = maskValue(value)
+		}
+		return v
+	case []interface{}:
+		for k, value := range v {
+			v[k] = maskValue(value)
+		}
+		return v
+	case json.Number:
+		return "number"
+	}
+	return jsonType(v)
+}
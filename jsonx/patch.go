@@ -0,0 +1,296 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidPatch is returned when a patch document is malformed.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchTestFailed is returned when a JSON Patch "test" operation fails.
+	ErrPatchTestFailed = errors.New("patch test operation failed")
+	// ErrPathNotFound is returned when a JSON Patch path does not exist.
+	ErrPathNotFound = errors.New("path not found")
+)
+
+// PatchOperation is a single RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ApplyJSONPatch applies the RFC 6902 JSON Patch to the document.
+func ApplyJSONPatch(doc, patch []byte) ([]byte, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+
+	var v interface{}
+	if err := unmarshal(doc, &v); err != nil {
+		return nil, err
+	}
+
+	for k, op := range ops {
+		var err error
+		if v, err = applyOperation(v, op); err != nil {
+			return nil, errors.WithMessagef(err, "operation %d (%s %s)", k, op.Op, op.Path)
+		}
+	}
+	return marshal(v)
+}
+
+// ApplyMergePatch applies the RFC 7396 JSON Merge Patch to the document.
+func ApplyMergePatch(doc, patch []byte) ([]byte, error) {
+	var v, p interface{}
+	if len(bytes.TrimSpace(doc)) > 0 {
+		if err := unmarshal(doc, &v); err != nil {
+			return nil, err
+		}
+	}
+	if err := unmarshal(patch, &p); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	return marshal(mergePatch(v, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+func applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, errors.Wrap(ErrInvalidPatch, `missing "value"`)
+		}
+		if err := unmarshal(op.Value, &value); err != nil {
+			return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return setPointer(doc, op.Path, value, true)
+	case "remove":
+		doc, _, err := removePointer(doc, op.Path)
+		return doc, err
+	case "replace":
+		if _, err := getPointer(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return setPointer(doc, op.Path, value, false)
+	case "move":
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, errors.Wrap(ErrInvalidPatch, "can not move a value into one of its children")
+		}
+		doc, moved, err := removePointer(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, op.Path, moved, true)
+	case "copy":
+		copied, err := getPointer(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return setPointer(doc, op.Path, deepCopy(copied), true)
+	case "test":
+		actual, err := getPointer(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !equal(actual, value) {
+			return nil, errors.WithStack(ErrPatchTestFailed)
+		}
+		return doc, nil
+	}
+	return nil, errors.Wrapf(ErrInvalidPatch, "unknown operation %q", op.Op)
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, errors.Wrapf(ErrInvalidPatch, "JSON pointer %q must start with a slash", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for k, token := range tokens {
+		tokens[k] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if allowEnd && token == "-" {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > length || (i == length && !allowEnd) || (len(token) > 1 && token[0] == '0') {
+		return 0, errors.Wrapf(ErrPathNotFound, "array index %q is out of bounds", token)
+	}
+	return i, nil
+}
+
+func getPointer(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	current := doc
+	for _, token := range tokens {
+		switch c := current.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, errors.Wrapf(ErrPathNotFound, "%s", pointer)
+			}
+			current = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(c), false)
+			if err != nil {
+				return nil, err
+			}
+			current = c[i]
+		default:
+			return nil, errors.Wrapf(ErrPathNotFound, "%s", pointer)
+		}
+	}
+	return current, nil
+}
+
+// setPointer sets the value at the pointer. If insert is true, values are
+// inserted into arrays, otherwise they are replaced.
+func setPointer(doc interface{}, pointer string, value interface{}, insert bool) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(doc, parentPointer)
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(p), insert)
+		if err != nil {
+			return nil, err
+		}
+		if insert {
+			p = append(p[:i], append([]interface{}{value}, p[i:]...)...)
+		} else {
+			p[i] = value
+		}
+		return setPointer(doc, parentPointer, p, false)
+	}
+	return nil, errors.Wrapf(ErrPathNotFound, "%s", pointer)
+}
+
+func removePointer(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := getPointer(doc, parentPointer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		removed, ok := p[last]
+		if !ok {
+			return nil, nil, errors.Wrapf(ErrPathNotFound, "%s", pointer)
+		}
+		delete(p, last)
+		return doc, removed, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		removed := p[i]
+		doc, err := setPointer(doc, parentPointer, append(p[:i:i], p[i+1:]...), false)
+		return doc, removed, err
+	}
+	return nil, nil, errors.Wrapf(ErrPathNotFound, "%s", pointer)
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, v := range c {
+			m[k] = deepCopy(v)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(c))
+		for k, v := range c {
+			s[k] = deepCopy(v)
+		}
+		return s
+	}
+	return v
+}
+
+func unmarshal(doc []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	if err := d.Decode(v); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("unexpected data after the JSON document")
+	}
+	return nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	out, err := json.Marshal(v)
+	return out, errors.WithStack(err)
+}
@@ -0,0 +1,29 @@
+package jwksx
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseCacheControl parses the max-age directive of a Cache-Control header.
+// noStore is true if the response must not be cached.
+func parseCacheControl(header string) (maxAge time.Duration, ok bool, noStore bool) {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		switch strings.ToLower(name) {
+		case "no-store", "no-cache":
+			return 0, false, true
+		case "max-age":
+			seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+			if err != nil || seconds < 0 {
+				continue
+			}
+			if seconds == 0 {
+				return 0, false, true
+			}
+			maxAge, ok = time.Duration(seconds)*time.Second, true
+		}
+	}
+	return maxAge, ok, false
+}
@@ -0,0 +1,53 @@
+package jwksx
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+
+	"github.com/pkg/errors"
+)
+
+var _ KeyCipher = (*AESGCMKeyCipher)(nil)
+
+// AESGCMKeyCipher encrypts private keys with AES-GCM. The nonce is prepended
+// to the ciphertext.
+type AESGCMKeyCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMKeyCipher returns a KeyCipher for the 16, 24, or 32 byte secret.
+func NewAESGCMKeyCipher(secret []byte) (*AESGCMKeyCipher, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &AESGCMKeyCipher{aead: aead}, nil
+}
+
+// Encrypt implements KeyCipher.
+func (c *AESGCMKeyCipher) Encrypt(_ context.Context, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt implements KeyCipher.
+func (c *AESGCMKeyCipher) Decrypt(_ context.Context, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.NonceSize() {
+		return nil, errors.New("the ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:c.aead.NonceSize()], ciphertext[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,211 @@
+package jwksx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/spf13/cobra"
+
+	"github.com/huanggze/x/cmdx"
+)
+
+// KeyManagerProvider returns the Manager the key commands operate on.
+type KeyManagerProvider func(cmd *cobra.Command) (*Manager, error)
+
+type (
+	managedKeyOutput struct {
+		ID        string    `json:"kid"`
+		Algorithm string    `json:"alg"`
+		State     KeyState  `json:"state"`
+		RetireAt  string    `json:"retire_at,omitempty"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	managedKeysOutput []managedKeyOutput
+)
+
+var _ cmdx.Table = (managedKeysOutput)(nil)
+
+func newManagedKeysOutput(keys ...ManagedKey) managedKeysOutput {
+	out := make(managedKeysOutput, len(keys))
+	for k, key := range keys {
+		out[k] = managedKeyOutput{
+			ID:        key.ID,
+			Algorithm: key.Algorithm,
+			State:     key.State,
+			CreatedAt: key.CreatedAt,
+		}
+		if retireAt := time.Time(key.RetireAt); !retireAt.IsZero() {
+			out[k].RetireAt = retireAt.Format(time.RFC3339)
+		}
+	}
+	return out
+}
+
+func (o managedKeysOutput) Header() []string {
+	return []string{"KEY ID", "ALGORITHM", "STATE", "CREATED AT", "RETIRE AT"}
+}
+
+func (o managedKeysOutput) Table() [][]string {
+	rows := make([][]string, len(o))
+	for k, key := range o {
+		retireAt := key.RetireAt
+		if retireAt == "" {
+			retireAt = cmdx.None
+		}
+		rows[k] = []string{key.ID, key.Algorithm, string(key.State), key.CreatedAt.Format(time.RFC3339), retireAt}
+	}
+	return rows
+}
+
+func (o managedKeysOutput) Interface() interface{} {
+	return o
+}
+
+func (o managedKeysOutput) Len() int {
+	return len(o)
+}
+
+func (o managedKeysOutput) IDs() []string {
+	ids := make([]string, len(o))
+	for k, key := range o {
+		ids[k] = key.ID
+	}
+	return ids
+}
+
+// NewKeysCmd returns the "keys" command with subcommands to create, list,
+// rotate, and retire managed JSON Web Keys.
+func NewKeysCmd(p KeyManagerProvider) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keys",
+		Short: "Manage the JSON Web Keys used to sign tokens",
+	}
+	cmdx.RegisterFormatFlags(cmd.PersistentFlags())
+	cmd.AddCommand(
+		NewKeysCreateCmd(p),
+		NewKeysListCmd(p),
+		NewKeysRotateCmd(p),
+		NewKeysRetireCmd(p),
+	)
+	return cmd
+}
+
+// NewKeysCreateCmd returns the "create" command.
+func NewKeysCreateCmd(p KeyManagerProvider) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Args:  cobra.NoArgs,
+		Short: "Generate a new JSON Web Key",
+		Long: `Generate a new JSON Web Key. The key becomes active if there is no active key yet.
+Otherwise it is published as inactive and becomes active on the next rotation.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			m, err := p(cmd)
+			if err != nil {
+				return err
+			}
+
+			alg, err := cmd.Flags().GetString("alg")
+			if err != nil {
+				return err
+			}
+
+			key, err := m.Create(cmd.Context(), jwa.SignatureAlgorithm(alg))
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not create the key:\n%+v\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			cmdx.PrintTable(cmd, newManagedKeysOutput(*key))
+			return nil
+		},
+	}
+	registerKeyAlgorithmFlag(cmd)
+	return cmd
+}
+
+// NewKeysListCmd returns the "list" command.
+func NewKeysListCmd(p KeyManagerProvider) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Args:  cobra.NoArgs,
+		Short: "List all JSON Web Keys",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			m, err := p(cmd)
+			if err != nil {
+				return err
+			}
+
+			keys, err := m.List(cmd.Context())
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not list the keys:\n%+v\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			cmdx.PrintTable(cmd, newManagedKeysOutput(keys...))
+			return nil
+		},
+	}
+}
+
+// NewKeysRotateCmd returns the "rotate" command.
+func NewKeysRotateCmd(p KeyManagerProvider) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Args:  cobra.NoArgs,
+		Short: "Rotate the active JSON Web Key",
+		Long: `Rotate the active JSON Web Key. The oldest key published by "create" becomes active, or a new key is generated.
+The previously active key is still published until the rotation overlap has passed.`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			m, err := p(cmd)
+			if err != nil {
+				return err
+			}
+
+			alg, err := cmd.Flags().GetString("alg")
+			if err != nil {
+				return err
+			}
+
+			key, err := m.Rotate(cmd.Context(), jwa.SignatureAlgorithm(alg))
+			if err != nil {
+				_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not rotate the keys:\n%+v\n", err)
+				return cmdx.FailSilently(cmd)
+			}
+
+			cmdx.PrintTable(cmd, newManagedKeysOutput(*key))
+			return nil
+		},
+	}
+	registerKeyAlgorithmFlag(cmd)
+	return cmd
+}
+
+// NewKeysRetireCmd returns the "retire" command.
+func NewKeysRetireCmd(p KeyManagerProvider) *cobra.Command {
+	return &cobra.Command{
+		Use:   "retire <kid> [<kid>...]",
+		Args:  cobra.MinimumNArgs(1),
+		Short: "Retire JSON Web Keys immediately",
+		Long:  "Retire JSON Web Keys immediately. Retired keys are no longer published, so tokens signed with them can no longer be verified.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := p(cmd)
+			if err != nil {
+				return err
+			}
+
+			for _, kid := range args {
+				if err := m.Retire(cmd.Context(), kid); err != nil {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Could not retire the key %s:\n%+v\n", kid, err)
+					return cmdx.FailSilently(cmd)
+				}
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), kid)
+			}
+			return nil
+		},
+	}
+}
+
+func registerKeyAlgorithmFlag(cmd *cobra.Command) {
+	cmd.Flags().String("alg", "", "The algorithm of the key, one of RS256, RS384, RS512, PS256, PS384, PS512, ES256, ES384, ES512, EdDSA. Defaults to the algorithm of the key manager.")
+}
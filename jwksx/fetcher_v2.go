@@ -3,6 +3,8 @@ package jwksx
 import (
 	"context"
 	"crypto/sha256"
+	"net/http"
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/ristretto/v2"
@@ -20,25 +22,47 @@ import (
 
 var ErrUnableToFindKeyID = errors.New("specified JWK kid can not be found in the JWK sets")
 
+const (
+	// DefaultMinRefreshInterval is the default minimum interval between two
+	// refreshes of a cached JWK set triggered by an unknown key ID.
+	DefaultMinRefreshInterval = 10 * time.Second
+
+	// refreshAhead is the fraction of the TTL after which a cached JWK set is
+	// refreshed in the background.
+	refreshAhead = 0.75
+)
+
 type (
 	fetcherNextOptions struct {
-		forceKID   string
-		cacheTTL   time.Duration
-		useCache   bool
-		httpClient *retryablehttp.Client
+		forceKID           string
+		cacheTTL           time.Duration
+		useCache           bool
+		httpClient         *retryablehttp.Client
+		minRefreshInterval time.Duration
+		fetcherModifiers   []fetcher.Modifier
 	}
 	// FetcherNext is a JWK fetcher that can be used to fetch JWKs from multiple locations.
 	FetcherNext struct {
 		cache *ristretto.Cache[[]byte, jwk.Set]
+
+		mu      sync.Mutex
+		entries map[[sha256.Size]byte]*cacheEntry
 	}
 	// FetcherNextOption is a functional option for the FetcherNext.
 	FetcherNextOption func(*fetcherNextOptions)
+
+	cacheEntry struct {
+		fetchedAt  time.Time
+		ttl        time.Duration
+		refreshing bool
+	}
 )
 
 // NewFetcherNext returns a new FetcherNext instance.
 func NewFetcherNext(cache *ristretto.Cache[[]byte, jwk.Set]) *FetcherNext {
 	return &FetcherNext{
-		cache: cache,
+		cache:   cache,
+		entries: make(map[[sha256.Size]byte]*cacheEntry),
 	}
 }
 
@@ -49,7 +73,8 @@ func WithForceKID(kid string) FetcherNextOption {
 	}
 }
 
-// WithCacheTTL sets the cache TTL. If not set, the TTL is unlimited.
+// WithCacheTTL sets the cache TTL. If not set, the TTL is unlimited. A
+// Cache-Control max-age returned by the JWKS endpoint takes precedence.
 func WithCacheTTL(ttl time.Duration) FetcherNextOption {
 	return func(o *fetcherNextOptions) {
 		o.cacheTTL = ttl
@@ -70,12 +95,33 @@ func WithHTTPClient(c *retryablehttp.Client) FetcherNextOption {
 	}
 }
 
+// WithFetcherModifiers passes the modifiers to the underlying fetcher, e.g. to
+// register additional schemes.
+func WithFetcherModifiers(modifiers ...fetcher.Modifier) FetcherNextOption {
+	return func(o *fetcherNextOptions) {
+		o.fetcherModifiers = modifiers
+	}
+}
+
+// WithMinRefreshInterval sets the minimum interval between two refreshes of a
+// cached JWK set that are triggered by an unknown key ID. This prevents
+// attackers from forcing constant refetches using random key IDs. Defaults to
+// DefaultMinRefreshInterval.
+func WithMinRefreshInterval(interval time.Duration) FetcherNextOption {
+	return func(o *fetcherNextOptions) {
+		o.minRefreshInterval = interval
+	}
+}
+
 func (f *FetcherNext) ResolveKey(ctx context.Context, locations string, modifiers ...FetcherNextOption) (jwk.Key, error) {
 	return f.ResolveKeyFromLocations(ctx, []string{locations}, modifiers...)
 }
 
+// ResolveKeyFromLocations resolves the key from the JWK sets at the given
+// locations. If the key ID is not found in a cached JWK set, the sets are
+// refetched once, in case the keys were rotated since they were cached.
 func (f *FetcherNext) ResolveKeyFromLocations(ctx context.Context, locations []string, modifiers ...FetcherNextOption) (jwk.Key, error) {
-	opts := new(fetcherNextOptions)
+	opts := &fetcherNextOptions{minRefreshInterval: DefaultMinRefreshInterval}
 	for _, m := range modifiers {
 		m(opts)
 	}
@@ -84,12 +130,44 @@ func (f *FetcherNext) ResolveKeyFromLocations(ctx context.Context, locations []s
 		return nil, errors.Errorf("a key ID must be specified when multiple JWK sets are configured")
 	}
 
+	set, err := f.fetchAll(ctx, locations, opts, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.forceKID != "" {
+		key, found := set.LookupKeyID(opts.forceKID)
+		if !found && opts.useCache {
+			// The key might have been rotated in after the set was cached.
+			if set, err = f.fetchAll(ctx, locations, opts, true); err != nil {
+				return nil, err
+			}
+			key, found = set.LookupKeyID(opts.forceKID)
+		}
+		if !found {
+			return nil, errors.WithStack(ErrUnableToFindKeyID)
+		}
+
+		return key, nil
+	}
+
+	// No KID was forced? Use the first key we can find.
+	key, found := set.Get(0)
+	if !found {
+		return nil, errors.WithStack(ErrUnableToFindKeyID)
+	}
+
+	return key, nil
+}
+
+// fetchAll fetches the JWK sets from all locations and merges them into one set.
+func (f *FetcherNext) fetchAll(ctx context.Context, locations []string, opts *fetcherNextOptions, refresh bool) (jwk.Set, error) {
 	set := jwk.NewSet()
 	eg := new(errgroup.Group)
 	for k := range locations {
 		location := locations[k]
 		eg.Go(func() error {
-			remoteSet, err := f.fetch(ctx, location, opts)
+			remoteSet, err := f.fetch(ctx, location, opts, refresh)
 			if err != nil {
 				return err
 			}
@@ -107,42 +185,42 @@ func (f *FetcherNext) ResolveKeyFromLocations(ctx context.Context, locations []s
 	if err := eg.Wait(); err != nil {
 		return nil, err
 	}
-
-	if opts.forceKID != "" {
-		key, found := set.LookupKeyID(opts.forceKID)
-		if !found {
-			return nil, errors.WithStack(ErrUnableToFindKeyID)
-		}
-
-		return key, nil
-	}
-
-	// No KID was forced? Use the first key we can find.
-	key, found := set.Get(0)
-	if !found {
-		return nil, errors.WithStack(ErrUnableToFindKeyID)
-	}
-
-	return key, nil
+	return set, nil
 }
 
 // fetch fetches the JWK set from the given location and if enabled, may use the cache to look up the JWK set.
-func (f *FetcherNext) fetch(ctx context.Context, location string, opts *fetcherNextOptions) (_ jwk.Set, err error) {
+// If refresh is true, the cache is bypassed unless the set was fetched less than the minimum refresh interval ago.
+func (f *FetcherNext) fetch(ctx context.Context, location string, opts *fetcherNextOptions, refresh bool) (_ jwk.Set, err error) {
 	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer("")
 	ctx, span := tracer.Start(ctx, "jwksx.FetcherNext.fetch", trace.WithAttributes(attribute.String("location", location)))
 	defer otelx.End(span, &err)
 
 	cacheKey := sha256.Sum256([]byte(location))
-	if opts.useCache {
+	if opts.useCache && !(refresh && f.reserveRefresh(cacheKey, opts.minRefreshInterval)) {
 		if result, found := f.cache.Get(cacheKey[:]); found {
+			f.refreshAhead(ctx, location, cacheKey, opts)
 			return result, nil
 		}
 	}
 
-	var fopts []fetcher.Modifier
+	return f.fetchAndCache(ctx, location, cacheKey, opts)
+}
+
+// fetchAndCache fetches the JWK set from the location and caches it if enabled.
+func (f *FetcherNext) fetchAndCache(ctx context.Context, location string, cacheKey [sha256.Size]byte, opts *fetcherNextOptions) (jwk.Set, error) {
+	ttl := opts.cacheTTL
+	cacheable := true
+	fopts := []fetcher.Modifier{fetcher.WithResponseHook(func(res *http.Response) {
+		if maxAge, ok, noStore := parseCacheControl(res.Header.Get("Cache-Control")); noStore {
+			cacheable = false
+		} else if ok {
+			ttl = maxAge
+		}
+	})}
 	if opts.httpClient != nil {
 		fopts = append(fopts, fetcher.WithClient(opts.httpClient))
 	}
+	fopts = append(fopts, opts.fetcherModifiers...)
 
 	result, err := fetcher.NewFetcher(fopts...).FetchContext(ctx, location)
 	if err != nil {
@@ -154,9 +232,52 @@ func (f *FetcherNext) fetch(ctx context.Context, location string, opts *fetcherN
 		return nil, errors.WithStack(herodot.ErrBadRequest.WithReason("failed to parse JWK set").WithWrap(err))
 	}
 
-	if opts.useCache {
-		f.cache.SetWithTTL(cacheKey[:], set, 1, opts.cacheTTL)
+	if opts.useCache && cacheable {
+		f.cache.SetWithTTL(cacheKey[:], set, 1, ttl)
+		f.mu.Lock()
+		f.entries[cacheKey] = &cacheEntry{fetchedAt: time.Now(), ttl: ttl}
+		f.mu.Unlock()
 	}
 
 	return set, nil
 }
+
+// reserveRefresh reports whether the cached JWK set may be refreshed now, and
+// if so, records the refresh so that concurrent callers do not refresh again.
+func (f *FetcherNext) reserveRefresh(cacheKey [sha256.Size]byte, interval time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e, ok := f.entries[cacheKey]
+	if !ok {
+		return true
+	}
+	if time.Since(e.fetchedAt) < interval {
+		return false
+	}
+	e.fetchedAt = time.Now()
+	return true
+}
+
+// refreshAhead refreshes the cached JWK set in the background if it is about
+// to expire.
+func (f *FetcherNext) refreshAhead(ctx context.Context, location string, cacheKey [sha256.Size]byte, opts *fetcherNextOptions) {
+	f.mu.Lock()
+	e, ok := f.entries[cacheKey]
+	if !ok || e.ttl <= 0 || e.refreshing || time.Since(e.fetchedAt) < time.Duration(float64(e.ttl)*refreshAhead) {
+		f.mu.Unlock()
+		return
+	}
+	e.refreshing = true
+	f.mu.Unlock()
+
+	go func() {
+		defer func() {
+			f.mu.Lock()
+			e.refreshing = false
+			f.mu.Unlock()
+		}()
+		// Errors are ignored; the cached set remains valid until it expires.
+		_, _ = f.fetchAndCache(context.WithoutCancel(ctx), location, cacheKey, opts)
+	}()
+}
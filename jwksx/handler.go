@@ -0,0 +1,47 @@
+package jwksx
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+)
+
+// WellKnownJWKSPath is the path where the public JWK set is conventionally published.
+const WellKnownJWKSPath = "/.well-known/jwks.json"
+
+// NewPublicSetHandler returns a handler that publishes the public keys of the
+// manager. Responses may be cached for maxAge, which should be shorter than the
+// rotation overlap so that verifiers pick up new keys before old ones are retired.
+func NewPublicSetHandler(m *Manager, writer herodot.Writer, maxAge time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		set, err := m.PublicSet(r.Context())
+		if err != nil {
+			writer.WriteError(w, r, err)
+			return
+		}
+
+		body, err := json.Marshal(set)
+		if err != nil {
+			writer.WriteError(w, r, errors.WithStack(err))
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		etag := `"` + hex.EncodeToString(sum[:]) + `"`
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		_, _ = w.Write(body)
+	})
+}
@@ -0,0 +1,519 @@
+package jwksx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/logrusx"
+	"github.com/huanggze/x/sqlxx"
+)
+
+const (
+	// KeyStateActive keys are used for signing and are published.
+	KeyStateActive KeyState = "active"
+	// KeyStateInactive keys are published so that tokens signed with them can
+	// still be verified, but are no longer used for signing.
+	KeyStateInactive KeyState = "inactive"
+	// KeyStateRetired keys are neither used for signing nor published.
+	KeyStateRetired KeyState = "retired"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("the managed JWK could not be found")
+	ErrNoActiveKey    = errors.New("no active managed JWK was found")
+	ErrUnsupportedAlg = errors.New("the key algorithm is not supported")
+)
+
+type (
+	// KeyState is the lifecycle state of a managed key.
+	KeyState string
+
+	// ManagedKey is a private JSON Web Key managed by the Manager.
+	ManagedKey struct {
+		ID        string   `json:"kid" db:"id"`
+		Algorithm string   `json:"alg" db:"algorithm"`
+		State     KeyState `json:"state" db:"state"`
+		// Key is the private JWK, or a JSON string with the base64 encoded
+		// ciphertext if the Manager has a KeyCipher.
+		Key         sqlxx.JSONRawMessage `json:"key" db:"key_data"`
+		RetireAt    sqlxx.NullTime       `json:"retire_at" db:"retire_at"`
+		ActivatedAt sqlxx.NullTime       `json:"activated_at" db:"activated_at"`
+		CreatedAt   time.Time            `json:"created_at" db:"created_at"`
+		UpdatedAt   time.Time            `json:"updated_at" db:"updated_at"`
+	}
+
+	// KeyStore persists managed keys.
+	KeyStore interface {
+		// ListKeys returns all keys ordered by creation date, oldest first.
+		ListKeys(ctx context.Context) ([]ManagedKey, error)
+		// CreateKey stores a new key.
+		CreateKey(ctx context.Context, key *ManagedKey) error
+		// UpdateKey updates the state and retirement date of an existing key.
+		UpdateKey(ctx context.Context, key *ManagedKey) error
+		// Transaction calls fn with a store whose changes are isolated from
+		// concurrent transactions until fn returns, so that keys can be read
+		// and changed atomically.
+		Transaction(ctx context.Context, fn func(ctx context.Context, store KeyStore) error) error
+	}
+
+	// KeyCipher encrypts private keys before they are stored and decrypts
+	// them after they are loaded.
+	KeyCipher interface {
+		Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+		Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+	}
+
+	managerOptions struct {
+		alg              jwa.SignatureAlgorithm
+		rotationInterval time.Duration
+		overlap          time.Duration
+		now              func() time.Time
+		l                *logrusx.Logger
+		cipher           KeyCipher
+	}
+	// Manager generates, rotates, and retires JSON Web Keys stored in a KeyStore.
+	Manager struct {
+		store KeyStore
+		opts  *managerOptions
+	}
+	// ManagerOption is a functional option for the Manager.
+	ManagerOption func(*managerOptions)
+)
+
+func (ManagedKey) TableName() string {
+	return "jwk_managed_keys"
+}
+
+// JWK parses the private JSON Web Key. Encrypted keys can only be parsed by
+// the Manager which has the KeyCipher.
+func (k *ManagedKey) JWK() (jwk.Key, error) {
+	if k.encrypted() {
+		return nil, errors.Errorf("managed JWK %s is encrypted", k.ID)
+	}
+	key, err := jwk.ParseKey(k.Key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse managed JWK %s", k.ID)
+	}
+	return key, nil
+}
+
+// encrypted reports whether the key holds a ciphertext instead of a JWK.
+func (k *ManagedKey) encrypted() bool {
+	return len(k.Key) > 0 && k.Key[0] == '"'
+}
+
+// activatedAt returns when the key became active. Keys stored before the
+// activation time was recorded fall back to their creation time.
+func (k *ManagedKey) activatedAt() time.Time {
+	if activatedAt := time.Time(k.ActivatedAt); !activatedAt.IsZero() {
+		return activatedAt
+	}
+	return k.CreatedAt
+}
+
+// published reports whether the key is part of the public JWK set at the given time.
+func (k *ManagedKey) published(now time.Time) bool {
+	switch k.State {
+	case KeyStateActive:
+		return true
+	case KeyStateInactive:
+		retireAt := time.Time(k.RetireAt)
+		return retireAt.IsZero() || now.Before(retireAt)
+	}
+	return false
+}
+
+// WithKeyAlgorithm sets the algorithm of generated keys. Defaults to ES256.
+func WithKeyAlgorithm(alg jwa.SignatureAlgorithm) ManagerOption {
+	return func(o *managerOptions) {
+		o.alg = alg
+	}
+}
+
+// WithRotationInterval sets the interval after which the active key is rotated
+// by RotateIfDue. Defaults to 30 days.
+func WithRotationInterval(interval time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		o.rotationInterval = interval
+	}
+}
+
+// WithRotationOverlap sets how long a rotated key is still published so that
+// tokens signed with it can be verified. Defaults to 24 hours.
+func WithRotationOverlap(overlap time.Duration) ManagerOption {
+	return func(o *managerOptions) {
+		o.overlap = overlap
+	}
+}
+
+// WithManagerClock sets the clock of the manager.
+func WithManagerClock(now func() time.Time) ManagerOption {
+	return func(o *managerOptions) {
+		o.now = now
+	}
+}
+
+// WithManagerLogger sets the logger of the manager.
+func WithManagerLogger(l *logrusx.Logger) ManagerOption {
+	return func(o *managerOptions) {
+		o.l = l
+	}
+}
+
+// WithKeyCipher encrypts private keys with the cipher before they are stored.
+// Without a cipher, private keys are stored in plaintext and anyone with read
+// access to the store can sign tokens. Keys stored in plaintext remain readable
+// after a cipher was added.
+func WithKeyCipher(c KeyCipher) ManagerOption {
+	return func(o *managerOptions) {
+		o.cipher = c
+	}
+}
+
+// NewManager returns a new Manager for the keys in the store.
+func NewManager(store KeyStore, opts ...ManagerOption) *Manager {
+	o := &managerOptions{
+		alg:              jwa.ES256,
+		rotationInterval: 30 * 24 * time.Hour,
+		overlap:          24 * time.Hour,
+		now:              time.Now,
+		l:                logrusx.New("", ""),
+	}
+	for _, m := range opts {
+		m(o)
+	}
+	return &Manager{store: store, opts: o}
+}
+
+// GenerateKey generates a new private JSON Web Key for the algorithm with the given key ID.
+func GenerateKey(alg jwa.SignatureAlgorithm, kid string) (jwk.Key, error) {
+	var raw interface{}
+	var err error
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		raw, err = rsa.GenerateKey(rand.Reader, 4096)
+	case jwa.ES256:
+		raw, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwa.ES384:
+		raw, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwa.ES512:
+		raw, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case jwa.EdDSA:
+		_, raw, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, errors.Wrapf(ErrUnsupportedAlg, "algorithm %q", alg)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	key, err := jwk.New(raw)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for k, v := range map[string]interface{}{
+		jwk.KeyIDKey:     kid,
+		jwk.AlgorithmKey: alg,
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	} {
+		if err := key.Set(k, v); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return key, nil
+}
+
+// List returns all managed keys, oldest first.
+func (m *Manager) List(ctx context.Context) ([]ManagedKey, error) {
+	return m.store.ListKeys(ctx)
+}
+
+// Create generates a new key. The key becomes active if there is no active key
+// yet, otherwise it is published as inactive so that it can be rolled out
+// before it is used for signing. If alg is empty, the default algorithm is used.
+func (m *Manager) Create(ctx context.Context, alg jwa.SignatureAlgorithm) (key *ManagedKey, err error) {
+	err = m.store.Transaction(ctx, func(ctx context.Context, store KeyStore) error {
+		keys, err := store.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		state := KeyStateInactive
+		if !slices.ContainsFunc(keys, func(k ManagedKey) bool { return k.State == KeyStateActive }) {
+			state = KeyStateActive
+		}
+		key, err = m.create(ctx, store, alg, state)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Rotate activates the oldest key previously published by Create, or generates
+// a new active key if there is none. Previously active keys become inactive and
+// are retired once the rotation overlap has passed.
+func (m *Manager) Rotate(ctx context.Context, alg jwa.SignatureAlgorithm) (key *ManagedKey, err error) {
+	err = m.store.Transaction(ctx, func(ctx context.Context, store KeyStore) error {
+		key, err = m.rotate(ctx, store, alg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.opts.l.WithField("kid", key.ID).WithField("alg", key.Algorithm).Info("Rotated managed JSON Web Key.")
+	return key, nil
+}
+
+func (m *Manager) rotate(ctx context.Context, store KeyStore, alg jwa.SignatureAlgorithm) (*ManagedKey, error) {
+	keys, err := store.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *ManagedKey
+	if next := slices.IndexFunc(keys, func(k ManagedKey) bool {
+		return k.State == KeyStateInactive && time.Time(k.RetireAt).IsZero() && (alg == "" || k.Algorithm == alg.String())
+	}); next >= 0 {
+		key = &keys[next]
+		key.State = KeyStateActive
+		key.ActivatedAt = sqlxx.NullTime(m.opts.now().UTC())
+		if err := store.UpdateKey(ctx, key); err != nil {
+			return nil, err
+		}
+	} else if key, err = m.create(ctx, store, alg, KeyStateActive); err != nil {
+		return nil, err
+	}
+
+	retireAt := m.opts.now().Add(m.opts.overlap).UTC()
+	for k := range keys {
+		if keys[k].State != KeyStateActive || keys[k].ID == key.ID {
+			continue
+		}
+		keys[k].State = KeyStateInactive
+		keys[k].RetireAt = sqlxx.NullTime(retireAt)
+		if err := store.UpdateKey(ctx, &keys[k]); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// Retire immediately retires the key with the given key ID.
+func (m *Manager) Retire(ctx context.Context, kid string) error {
+	if err := m.store.Transaction(ctx, func(ctx context.Context, store KeyStore) error {
+		keys, err := store.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		for k := range keys {
+			if keys[k].ID != kid {
+				continue
+			}
+			keys[k].State = KeyStateRetired
+			keys[k].RetireAt = sqlxx.NullTime(m.opts.now().UTC())
+			return store.UpdateKey(ctx, &keys[k])
+		}
+		return errors.Wrapf(ErrKeyNotFound, "kid %q", kid)
+	}); err != nil {
+		return err
+	}
+
+	m.opts.l.WithField("kid", kid).Info("Retired managed JSON Web Key.")
+	return nil
+}
+
+// RotateIfDue rotates the active key if it was activated longer than the
+// rotation interval ago, creates an active key if there is none, and retires inactive keys
+// whose overlap has passed. It returns true if a new key was created.
+//
+// The keys are checked and changed in one store transaction, so that several
+// instances sharing the store can call RotateIfDue concurrently without
+// activating more than one key.
+func (m *Manager) RotateIfDue(ctx context.Context) (bool, error) {
+	var retired []string
+	var key *ManagedKey
+	if err := m.store.Transaction(ctx, func(ctx context.Context, store KeyStore) error {
+		keys, err := store.ListKeys(ctx)
+		if err != nil {
+			return err
+		}
+
+		now := m.opts.now()
+		for k := range keys {
+			if keys[k].State == KeyStateInactive && !keys[k].published(now) {
+				keys[k].State = KeyStateRetired
+				if err := store.UpdateKey(ctx, &keys[k]); err != nil {
+					return err
+				}
+				retired = append(retired, keys[k].ID)
+			}
+		}
+
+		active := slices.IndexFunc(keys, func(k ManagedKey) bool { return k.State == KeyStateActive })
+		if active >= 0 && now.Before(keys[active].activatedAt().Add(m.opts.rotationInterval)) {
+			return nil
+		}
+		key, err = m.rotate(ctx, store, "")
+		return err
+	}); err != nil {
+		return false, err
+	}
+
+	for _, kid := range retired {
+		m.opts.l.WithField("kid", kid).Info("Retired managed JSON Web Key after the rotation overlap passed.")
+	}
+	if key == nil {
+		return false, nil
+	}
+	m.opts.l.WithField("kid", key.ID).WithField("alg", key.Algorithm).Info("Rotated managed JSON Web Key.")
+	return true, nil
+}
+
+// Run calls RotateIfDue every interval until the context is canceled.
+func (m *Manager) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := m.RotateIfDue(ctx); err != nil {
+			m.opts.l.WithError(err).Error("Unable to rotate managed JSON Web Keys.")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// PublicSet returns the public keys of all active and inactive keys.
+func (m *Manager) PublicSet(ctx context.Context) (jwk.Set, error) {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.opts.now()
+	set := jwk.NewSet()
+	for k := range keys {
+		if !keys[k].published(now) {
+			continue
+		}
+		key, err := m.jwk(ctx, &keys[k])
+		if err != nil {
+			return nil, err
+		}
+		pub, err := key.PublicKey()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		set.Add(pub)
+	}
+	return set, nil
+}
+
+// Signer returns a Signer for the active key.
+func (m *Manager) Signer(ctx context.Context) (*Signer, error) {
+	keys, err := m.store.ListKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use the most recent active key.
+	for k := len(keys) - 1; k >= 0; k-- {
+		if keys[k].State != KeyStateActive {
+			continue
+		}
+		key, err := m.jwk(ctx, &keys[k])
+		if err != nil {
+			return nil, err
+		}
+		set := jwk.NewSet()
+		set.Add(key)
+		return NewSignerFromSet(ctx, set)
+	}
+	return nil, errors.WithStack(ErrNoActiveKey)
+}
+
+func (m *Manager) create(ctx context.Context, store KeyStore, alg jwa.SignatureAlgorithm, state KeyState) (*ManagedKey, error) {
+	if alg == "" {
+		alg = m.opts.alg
+	}
+
+	kid := uuid.Must(uuid.NewV4()).String()
+	key, err := GenerateKey(alg, kid)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if m.opts.cipher != nil {
+		ciphertext, err := m.opts.cipher.Encrypt(ctx, raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to encrypt managed JWK %s", kid)
+		}
+		if raw, err = json.Marshal(ciphertext); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	now := m.opts.now().UTC()
+	mk := &ManagedKey{
+		ID:        kid,
+		Algorithm: alg.String(),
+		State:     state,
+		Key:       raw,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if state == KeyStateActive {
+		mk.ActivatedAt = sqlxx.NullTime(now)
+	}
+	if err := store.CreateKey(ctx, mk); err != nil {
+		return nil, err
+	}
+	return mk, nil
+}
+
+// jwk parses the private JSON Web Key, decrypting it if necessary.
+func (m *Manager) jwk(ctx context.Context, k *ManagedKey) (jwk.Key, error) {
+	if !k.encrypted() {
+		return k.JWK()
+	}
+	if m.opts.cipher == nil {
+		return nil, errors.Errorf("managed JWK %s is encrypted but no key cipher is configured", k.ID)
+	}
+
+	var ciphertext []byte
+	if err := json.Unmarshal(k.Key, &ciphertext); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode managed JWK %s", k.ID)
+	}
+	plaintext, err := m.opts.cipher.Decrypt(ctx, ciphertext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decrypt managed JWK %s", k.ID)
+	}
+	key, err := jwk.ParseKey(plaintext)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse managed JWK %s", k.ID)
+	}
+	return key, nil
+}
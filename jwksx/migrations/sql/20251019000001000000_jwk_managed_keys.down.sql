@@ -0,0 +1 @@
+DROP TABLE jwk_managed_keys;
@@ -0,0 +1,11 @@
+CREATE TABLE jwk_managed_keys (
+  id VARCHAR(64) NOT NULL,
+  algorithm VARCHAR(32) NOT NULL,
+  state VARCHAR(32) NOT NULL,
+  key_data TEXT NOT NULL,
+  retire_at TIMESTAMP NULL,
+  activated_at TIMESTAMP NULL,
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (id)
+);
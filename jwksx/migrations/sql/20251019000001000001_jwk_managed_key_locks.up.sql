@@ -0,0 +1,6 @@
+CREATE TABLE jwk_managed_key_locks (
+  id VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id)
+);
+
+INSERT INTO jwk_managed_key_locks (id) VALUES ('rotation');
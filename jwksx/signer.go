@@ -0,0 +1,137 @@
+package jwksx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+
+	"github.com/huanggze/x/fetcher"
+)
+
+var ErrNoSigningKey = errors.New("no private JWK suitable for signing was found in the JWK set")
+
+type (
+	signerOptions struct {
+		kid string
+	}
+	// Signer signs JWTs using a private key from a local JWK set.
+	Signer struct {
+		key jwk.Key
+		alg jwa.SignatureAlgorithm
+	}
+	// SignerOption is a functional option for the Signer.
+	SignerOption func(*signerOptions)
+)
+
+// WithSigningKeyID selects the key with the given key ID. By default, the
+// first private key in the set is used.
+func WithSigningKeyID(kid string) SignerOption {
+	return func(o *signerOptions) {
+		o.kid = kid
+	}
+}
+
+// NewSigner loads the JWK set from a local location (file:// or base64://) and
+// returns a Signer for one of its private keys.
+func NewSigner(ctx context.Context, location string, opts ...SignerOption) (*Signer, error) {
+	result, err := fetcher.NewFetcher(fetcher.WithAllowedSchemes("file", "base64")).FetchContext(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+
+	set, err := jwk.ParseReader(result)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse JWK set")
+	}
+
+	return NewSignerFromSet(ctx, set, opts...)
+}
+
+// NewSignerFromSet returns a Signer for one of the private keys in the set.
+func NewSignerFromSet(ctx context.Context, set jwk.Set, opts ...SignerOption) (*Signer, error) {
+	o := new(signerOptions)
+	for _, m := range opts {
+		m(o)
+	}
+
+	iterator := set.Iterate(ctx)
+	for iterator.Next(ctx) {
+		// Pair().Value is always of type jwk.Key when generated by Iterate.
+		key := iterator.Pair().Value.(jwk.Key)
+		if o.kid != "" && key.KeyID() != o.kid {
+			continue
+		}
+		if usage := key.KeyUsage(); usage != "" && usage != jwk.ForSignature.String() {
+			continue
+		}
+
+		alg, err := signingAlgorithm(key)
+		if err != nil {
+			continue
+		}
+		return &Signer{key: key, alg: alg}, nil
+	}
+
+	return nil, errors.WithStack(ErrNoSigningKey)
+}
+
+// KeyID returns the key ID of the signing key.
+func (s *Signer) KeyID() string {
+	return s.key.KeyID()
+}
+
+// Sign signs the claims and returns the compact JWT. The "kid" header is set
+// to the key ID of the signing key.
+func (s *Signer) Sign(_ context.Context, claims map[string]interface{}) (string, error) {
+	t := jwt.New()
+	for k, v := range claims {
+		if err := t.Set(k, v); err != nil {
+			return "", errors.Wrapf(err, "unable to set claim %q", k)
+		}
+	}
+
+	signed, err := jwt.Sign(t, s.alg, s.key)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return string(signed), nil
+}
+
+// signingAlgorithm returns the algorithm of the private key, falling back to
+// the default algorithm of the key type if the key has no "alg" parameter.
+func signingAlgorithm(key jwk.Key) (jwa.SignatureAlgorithm, error) {
+	var alg jwa.SignatureAlgorithm
+	switch k := key.(type) {
+	case jwk.RSAPrivateKey:
+		alg = jwa.RS256
+	case jwk.ECDSAPrivateKey:
+		var raw ecdsa.PrivateKey
+		if err := k.Raw(&raw); err != nil {
+			return "", errors.WithStack(err)
+		}
+		switch raw.Curve {
+		case elliptic.P384():
+			alg = jwa.ES384
+		case elliptic.P521():
+			alg = jwa.ES512
+		default:
+			alg = jwa.ES256
+		}
+	case jwk.OKPPrivateKey:
+		alg = jwa.EdDSA
+	default:
+		return "", errors.Errorf("unsupported key type %T", key)
+	}
+
+	if key.Algorithm() != "" {
+		if err := alg.Accept(key.Algorithm()); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	return alg, nil
+}
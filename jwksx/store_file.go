@@ -0,0 +1,148 @@
+package jwksx
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	_ KeyStore = (*FileStore)(nil)
+	_ KeyStore = (*fileStoreTx)(nil)
+)
+
+// FileStore stores managed keys as JSON in a file only readable by the owner.
+//
+// The private keys are stored in plaintext unless the Manager is configured
+// with WithKeyCipher.
+//
+// Transactions are only isolated within the process and are not rolled back,
+// so the file must not be shared by several instances. Use the SQLStore
+// instead.
+type (
+	FileStore struct {
+		path string
+		mu   sync.Mutex
+	}
+	// fileStoreTx is the FileStore within a transaction, i.e. with the lock held.
+	fileStoreTx struct {
+		s *FileStore
+	}
+)
+
+// NewFileStore returns a new FileStore. The file is created on the first write.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// ListKeys implements KeyStore.
+func (s *FileStore) ListKeys(ctx context.Context) ([]ManagedKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (&fileStoreTx{s: s}).ListKeys(ctx)
+}
+
+// CreateKey implements KeyStore.
+func (s *FileStore) CreateKey(ctx context.Context, key *ManagedKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (&fileStoreTx{s: s}).CreateKey(ctx, key)
+}
+
+// UpdateKey implements KeyStore.
+func (s *FileStore) UpdateKey(ctx context.Context, key *ManagedKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (&fileStoreTx{s: s}).UpdateKey(ctx, key)
+}
+
+// Transaction implements KeyStore.
+func (s *FileStore) Transaction(ctx context.Context, fn func(ctx context.Context, store KeyStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(ctx, &fileStoreTx{s: s})
+}
+
+func (tx *fileStoreTx) ListKeys(_ context.Context) ([]ManagedKey, error) {
+	return tx.s.read()
+}
+
+func (tx *fileStoreTx) CreateKey(_ context.Context, key *ManagedKey) error {
+	s := tx.s
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for k := range keys {
+		if keys[k].ID == key.ID {
+			return errors.Errorf("a managed JWK with kid %q exists already", key.ID)
+		}
+	}
+	return s.write(append(keys, *key))
+}
+
+func (tx *fileStoreTx) UpdateKey(_ context.Context, key *ManagedKey) error {
+	s := tx.s
+	keys, err := s.read()
+	if err != nil {
+		return err
+	}
+	for k := range keys {
+		if keys[k].ID == key.ID {
+			key.UpdatedAt = time.Now().UTC()
+			keys[k] = *key
+			return s.write(keys)
+		}
+	}
+	return errors.Wrapf(ErrKeyNotFound, "kid %q", key.ID)
+}
+
+func (tx *fileStoreTx) Transaction(ctx context.Context, fn func(ctx context.Context, store KeyStore) error) error {
+	return fn(ctx, tx)
+}
+
+func (s *FileStore) read() ([]ManagedKey, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file: %s", s.path)
+	}
+
+	var keys []ManagedKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode file: %s", s.path)
+	}
+	return keys, nil
+}
+
+// write atomically replaces the file with the keys.
+func (s *FileStore) write(keys []ManagedKey) error {
+	b, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrapf(err, "unable to create file: %s", s.path)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "unable to write file: %s", tmp.Name())
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, "unable to close file: %s", tmp.Name())
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return errors.Wrapf(err, "unable to replace file: %s", s.path)
+	}
+	return nil
+}
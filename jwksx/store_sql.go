@@ -0,0 +1,73 @@
+package jwksx
+
+import (
+	"context"
+	"embed"
+
+	"github.com/ory/pop/v6"
+
+	"github.com/huanggze/x/popx"
+	"github.com/huanggze/x/sqlcon"
+)
+
+// Migrations of the SQL key store. Apply by merging with your local migrations using
+// fsx.Merge() and then passing all to the migration box.
+//
+//go:embed migrations/sql/*.sql
+var Migrations embed.FS
+
+var _ KeyStore = (*SQLStore)(nil)
+
+// managedKeyLock is the row of the jwk_managed_key_locks table which is locked
+// by transactions.
+const managedKeyLock = "rotation"
+
+// SQLStore stores managed keys in a SQL database, which may be shared by
+// several instances. Transactions lock a single row of the
+// jwk_managed_key_locks table, so that concurrent rotations are serialized.
+//
+// The private keys are stored in plaintext unless the Manager is configured
+// with WithKeyCipher.
+type SQLStore struct {
+	c *pop.Connection
+}
+
+// NewSQLStore returns a new SQLStore.
+func NewSQLStore(c *pop.Connection) *SQLStore {
+	return &SQLStore{c: c}
+}
+
+// ListKeys implements KeyStore.
+func (s *SQLStore) ListKeys(ctx context.Context) ([]ManagedKey, error) {
+	var keys []ManagedKey
+	if err := sqlcon.HandleError(popx.GetConnection(ctx, s.c).Q().Order("created_at ASC").All(&keys)); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateKey implements KeyStore.
+func (s *SQLStore) CreateKey(ctx context.Context, key *ManagedKey) error {
+	return sqlcon.HandleError(popx.GetConnection(ctx, s.c).Create(key))
+}
+
+// UpdateKey implements KeyStore.
+func (s *SQLStore) UpdateKey(ctx context.Context, key *ManagedKey) error {
+	return sqlcon.HandleError(popx.GetConnection(ctx, s.c).Update(key))
+}
+
+// Transaction implements KeyStore.
+func (s *SQLStore) Transaction(ctx context.Context, fn func(ctx context.Context, store KeyStore) error) error {
+	return popx.Transaction(ctx, s.c, func(ctx context.Context, c *pop.Connection) error {
+		// The keys are read after the lock was acquired, so that they include
+		// the changes of the transaction which held the lock before.
+		q := "SELECT id FROM jwk_managed_key_locks WHERE id = ?"
+		if c.Dialect.Name() != "sqlite3" {
+			q += " FOR UPDATE"
+		}
+		if err := sqlcon.HandleError(c.RawQuery(q, managedKeyLock).Exec()); err != nil {
+			return err
+		}
+		return fn(ctx, s)
+	})
+}
@@ -0,0 +1,215 @@
+package jwksx
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAlgorithmNotAllowed = errors.New("the token signing algorithm is not allowed")
+	ErrInvalidSignature    = errors.New("the token signature is invalid")
+	ErrTokenExpired        = errors.New("the token is expired")
+	ErrTokenNotYetValid    = errors.New("the token is not valid yet")
+	ErrTokenUsedBeforeIAT  = errors.New("the token was issued in the future")
+	ErrInvalidIssuer       = errors.New("the token issuer is not allowed")
+	ErrInvalidAudience     = errors.New("the token audience is not allowed")
+	ErrMissingClaim        = errors.New("the token is missing a required claim")
+
+	// DefaultAllowedAlgorithms are the asymmetric algorithms allowed by default.
+	DefaultAllowedAlgorithms = []jwa.SignatureAlgorithm{
+		jwa.RS256, jwa.RS384, jwa.RS512,
+		jwa.PS256, jwa.PS384, jwa.PS512,
+		jwa.ES256, jwa.ES384, jwa.ES512,
+		jwa.EdDSA,
+	}
+)
+
+type (
+	verifierOptions struct {
+		algorithms  []jwa.SignatureAlgorithm
+		issuers     []string
+		audiences   []string
+		required    []string
+		skew        time.Duration
+		now         func() time.Time
+		fetcherOpts []FetcherNextOption
+	}
+	// Verifier verifies compact JWS/JWT against the JSON Web Keys fetched from
+	// one or more JWKS locations.
+	Verifier struct {
+		fetcher   *FetcherNext
+		locations []string
+		opts      *verifierOptions
+	}
+	// VerifierOption is a functional option for the Verifier.
+	VerifierOption func(*verifierOptions)
+
+	// ClaimError is returned when a claim of a token fails validation. Use
+	// errors.Is with e.g. ErrTokenExpired to check for the reason.
+	ClaimError struct {
+		Claim string
+		Err   error
+	}
+)
+
+func (e *ClaimError) Error() string {
+	return fmt.Sprintf("invalid %q claim: %s", e.Claim, e.Err)
+}
+
+func (e *ClaimError) Unwrap() error {
+	return e.Err
+}
+
+// WithAllowedAlgorithms sets the allowed signing algorithms. Defaults to
+// DefaultAllowedAlgorithms. The "none" algorithm is never allowed.
+func WithAllowedAlgorithms(algorithms ...jwa.SignatureAlgorithm) VerifierOption {
+	return func(o *verifierOptions) {
+		o.algorithms = algorithms
+	}
+}
+
+// WithIssuer requires the "iss" claim to equal one of the given issuers.
+func WithIssuer(issuers ...string) VerifierOption {
+	return func(o *verifierOptions) {
+		o.issuers = issuers
+	}
+}
+
+// WithAudience requires the "aud" claim to contain at least one of the given audiences.
+func WithAudience(audiences ...string) VerifierOption {
+	return func(o *verifierOptions) {
+		o.audiences = audiences
+	}
+}
+
+// WithRequiredClaims requires the token to contain the given claims, e.g. "exp".
+func WithRequiredClaims(claims ...string) VerifierOption {
+	return func(o *verifierOptions) {
+		o.required = claims
+	}
+}
+
+// WithClockSkew sets the leeway applied to the "exp", "nbf", and "iat" claims.
+func WithClockSkew(skew time.Duration) VerifierOption {
+	return func(o *verifierOptions) {
+		o.skew = skew
+	}
+}
+
+// WithClock sets the clock used to validate the time-based claims.
+func WithClock(now func() time.Time) VerifierOption {
+	return func(o *verifierOptions) {
+		o.now = now
+	}
+}
+
+// WithFetcherNextOptions sets the options used to resolve the verification key,
+// e.g. WithCacheEnabled.
+func WithFetcherNextOptions(opts ...FetcherNextOption) VerifierOption {
+	return func(o *verifierOptions) {
+		o.fetcherOpts = opts
+	}
+}
+
+// NewVerifier returns a new Verifier that resolves keys from the JWK sets at
+// the given locations using the FetcherNext.
+func NewVerifier(f *FetcherNext, locations []string, opts ...VerifierOption) *Verifier {
+	o := &verifierOptions{
+		algorithms: DefaultAllowedAlgorithms,
+		now:        time.Now,
+	}
+	for _, m := range opts {
+		m(o)
+	}
+	return &Verifier{fetcher: f, locations: locations, opts: o}
+}
+
+// Verify verifies the signature of the compact JWT and validates its claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (jwt.Token, error) {
+	payload, err := v.VerifySignature(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := jwt.Parse(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse token claims")
+	}
+	if err := v.validate(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// VerifySignature verifies the signature of the compact JWS and returns its
+// payload. Claims are not validated.
+func (v *Verifier) VerifySignature(ctx context.Context, token string) ([]byte, error) {
+	msg, err := jws.ParseString(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse token")
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, errors.Errorf("expected exactly one signature but got %d", len(msg.Signatures()))
+	}
+
+	headers := msg.Signatures()[0].ProtectedHeaders()
+	alg := headers.Algorithm()
+	if alg == jwa.NoSignature || !slices.Contains(v.opts.algorithms, alg) {
+		return nil, errors.Wrapf(ErrAlgorithmNotAllowed, "algorithm %q", alg)
+	}
+
+	fopts := v.opts.fetcherOpts
+	if kid := headers.KeyID(); kid != "" {
+		fopts = append(slices.Clone(fopts), WithForceKID(kid))
+	}
+	key, err := v.fetcher.ResolveKeyFromLocations(ctx, v.locations, fopts...)
+	if err != nil {
+		return nil, err
+	}
+	if key.Algorithm() != "" && key.Algorithm() != alg.String() {
+		return nil, errors.Wrapf(ErrAlgorithmNotAllowed, "algorithm %q does not match key algorithm %q", alg, key.Algorithm())
+	}
+
+	payload, err := jws.Verify([]byte(token), alg, key)
+	if err != nil {
+		return nil, errors.WithStack(ErrInvalidSignature)
+	}
+	return payload, nil
+}
+
+func (v *Verifier) validate(t jwt.Token) error {
+	for _, claim := range v.opts.required {
+		if _, ok := t.Get(claim); !ok {
+			return errors.WithStack(&ClaimError{Claim: claim, Err: ErrMissingClaim})
+		}
+	}
+
+	now := v.opts.now()
+	if exp := t.Expiration(); !exp.IsZero() && !now.Before(exp.Add(v.opts.skew)) {
+		return errors.WithStack(&ClaimError{Claim: jwt.ExpirationKey, Err: ErrTokenExpired})
+	}
+	if nbf := t.NotBefore(); !nbf.IsZero() && now.Add(v.opts.skew).Before(nbf) {
+		return errors.WithStack(&ClaimError{Claim: jwt.NotBeforeKey, Err: ErrTokenNotYetValid})
+	}
+	if iat := t.IssuedAt(); !iat.IsZero() && now.Add(v.opts.skew).Before(iat) {
+		return errors.WithStack(&ClaimError{Claim: jwt.IssuedAtKey, Err: ErrTokenUsedBeforeIAT})
+	}
+
+	if len(v.opts.issuers) > 0 && !slices.Contains(v.opts.issuers, t.Issuer()) {
+		return errors.WithStack(&ClaimError{Claim: jwt.IssuerKey, Err: ErrInvalidIssuer})
+	}
+	if len(v.opts.audiences) > 0 && !slices.ContainsFunc(t.Audience(), func(aud string) bool {
+		return slices.Contains(v.opts.audiences, aud)
+	}) {
+		return errors.WithStack(&ClaimError{Claim: jwt.AudienceKey, Err: ErrInvalidAudience})
+	}
+
+	return nil
+}
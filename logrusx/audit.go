@@ -0,0 +1,399 @@
+package logrusx
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/huanggze/x/jsonx"
+)
+
+// AuditOutcome is the result of an audited action.
+type AuditOutcome string
+
+const (
+	AuditOutcomeSuccess AuditOutcome = "success"
+	AuditOutcomeFailure AuditOutcome = "failure"
+	AuditOutcomeDenied  AuditOutcome = "denied"
+)
+
+// ErrAuditChainBroken is returned by VerifyAuditChain if an audit event was
+// modified, removed, or reordered.
+var ErrAuditChainBroken = errors.New("the audit log hash chain is broken")
+
+// auditBackupLayout is the time layout of the suffix of rotated audit files.
+const auditBackupLayout = "20060102T150405.000000000"
+
+// AuditEvent is a security relevant event, e.g. a login, a configuration
+// change, a key rotation, or a migration run.
+type AuditEvent struct {
+	// Actor is who performed the action, e.g. an identity or service ID.
+	Actor string `json:"actor"`
+	// Action is what was done, e.g. "login" or "keys.rotate".
+	Action string `json:"action"`
+	// Target is what the action was performed on.
+	Target string `json:"target,omitempty"`
+	// Outcome is the result of the action.
+	Outcome AuditOutcome `json:"outcome"`
+	// RequestID correlates the event with the request which caused it.
+	RequestID string `json:"request_id,omitempty"`
+	// Details are additional, action specific values.
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+type auditRecord struct {
+	AuditEvent
+	Time     string `json:"time"`
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
+
+// AuditLogger writes audit events to a sink separate from the application
+// logs. Audit events are not subject to the log level and are written in the
+// format and to the output configured under "log.audit".
+type AuditLogger struct {
+	entry  *logrus.Entry
+	out    io.Writer
+	closer io.Closer
+	chain  bool
+
+	mu       sync.Mutex
+	prevHash string
+}
+
+// WithAuditOutput sets the sink of the audit logger, overriding the
+// "log.audit.output" config key.
+func WithAuditOutput(w io.Writer) Option {
+	return func(o *options) {
+		o.auditOutput = w
+	}
+}
+
+// WithAuditHashChain enables hash chaining of audit events, which makes
+// modifications of the audit log detectable with VerifyAuditChain.
+//
+// The chain continues across rotated files, so the first event of a file
+// points to the last event of the previous file. If the output is a file, the
+// chain also continues after a restart: the previous hash is read from the
+// last event in the file, or in the most recent rotated file if the file is
+// empty. For other outputs, or if the last event has no hash, e.g. because it
+// was written in the text format, the chain starts over after a restart.
+func WithAuditHashChain() Option {
+	return func(o *options) {
+		o.auditHashChain = true
+	}
+}
+
+// NewAudit creates a new audit logger. The output is configured with
+// "log.audit.output", which is "stdout" (default), "stderr", or a file path.
+// Files are rotated when they exceed "log.audit.max_size" megabytes, keeping
+// "log.audit.max_backups" rotated files, or all of them if unset.
+func NewAudit(name, version string, opts ...Option) (*AuditLogger, error) {
+	o := newOptions(opts)
+	o.format = cmp.Or(o.format, o.c.String("log.audit.format"), "json")
+
+	a := &AuditLogger{chain: o.auditHashChain || o.c.Bool("log.audit.hash_chain")}
+	switch output := o.c.String("log.audit.output"); {
+	case o.auditOutput != nil:
+		a.out = o.auditOutput
+	case output == "" || output == "stdout":
+		a.out = os.Stdout
+	case output == "stderr":
+		a.out = os.Stderr
+	default:
+		maxSize, _ := strconv.ParseInt(o.c.String("log.audit.max_size"), 10, 64)
+		maxBackups, _ := strconv.Atoi(o.c.String("log.audit.max_backups"))
+		f, err := openRotatingFile(output, maxSize<<20, maxBackups)
+		if err != nil {
+			return nil, err
+		}
+		a.out, a.closer = f, f
+
+		if a.chain {
+			if a.prevHash, err = f.lastAuditHash(); err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+		}
+	}
+
+	l := logrus.New()
+	l.Out = a.out
+	l.Level = logrus.TraceLevel
+	for _, hook := range o.hooks {
+		l.AddHook(hook)
+	}
+	setFormatter(l, o)
+
+	a.entry = l.WithFields(logrus.Fields{
+		"audience": "audit", "service_name": name, "service_version": version})
+	return a, nil
+}
+
+// Log writes the audit event. Unlike application logs, errors writing the
+// event are returned to the caller.
+func (a *AuditLogger) Log(ctx context.Context, event AuditEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now().UTC()
+	record := auditRecord{AuditEvent: event, Time: now.Format(time.RFC3339Nano)}
+	if a.chain {
+		record.PrevHash = a.prevHash
+		hash, err := jsonx.CanonicalSHA256(record)
+		if err != nil {
+			return err
+		}
+		record.Hash = hash
+	}
+
+	// Text formatters print maps but not structs in a readable way.
+	var fields map[string]interface{}
+	if raw, err := json.Marshal(record); err != nil {
+		return errors.WithStack(err)
+	} else if err := json.Unmarshal(raw, &fields); err != nil {
+		return errors.WithStack(err)
+	}
+
+	entry := a.entry.WithContext(ctx).WithTime(now).WithField("audit", fields)
+	entry.Level = logrus.InfoLevel
+	entry.Message = event.Action
+	if err := entry.Logger.Hooks.Fire(logrus.InfoLevel, entry); err != nil {
+		return errors.WithStack(err)
+	}
+
+	serialized, err := entry.Bytes()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := a.out.Write(serialized); err != nil {
+		return errors.WithStack(err)
+	}
+
+	a.prevHash = record.Hash
+	return nil
+}
+
+// Close closes the audit log file, if any.
+func (a *AuditLogger) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return errors.WithStack(a.closer.Close())
+}
+
+// VerifyAuditChain verifies the hash chain of an audit log written with hash
+// chaining enabled in the "json" or "json_pretty" format. It returns the
+// number of verified events. The first event may point to an event in a
+// rotated file or written before a restart; to verify the chain across files,
+// pass the rotated files and the current file concatenated in order.
+func VerifyAuditChain(r io.Reader) (int, error) {
+	d := json.NewDecoder(r)
+	var prevHash string
+	for n := 0; ; n++ {
+		var line struct {
+			Audit map[string]json.RawMessage `json:"audit"`
+		}
+		if err := d.Decode(&line); errors.Is(err, io.EOF) {
+			return n, nil
+		} else if err != nil {
+			return n, errors.WithStack(err)
+		}
+
+		var hash, prev string
+		if err := json.Unmarshal(line.Audit["hash"], &hash); err != nil || hash == "" {
+			return n, errors.Wrapf(ErrAuditChainBroken, "event %d has no hash", n+1)
+		}
+		if raw, ok := line.Audit["prev_hash"]; ok {
+			if err := json.Unmarshal(raw, &prev); err != nil {
+				return n, errors.Wrapf(ErrAuditChainBroken, "event %d has an invalid previous hash", n+1)
+			}
+		}
+		if n > 0 && prev != prevHash {
+			return n, errors.Wrapf(ErrAuditChainBroken, "event %d does not follow the previous event", n+1)
+		}
+
+		delete(line.Audit, "hash")
+		computed, err := jsonx.CanonicalSHA256(line.Audit)
+		if err != nil {
+			return n, err
+		}
+		if computed != hash {
+			return n, errors.Wrapf(ErrAuditChainBroken, "event %d was modified", n+1)
+		}
+		prevHash = hash
+	}
+}
+
+// rotatingFile is an append-only file which is renamed to a timestamped backup
+// once it exceeds maxSize bytes.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	f          *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.WithStack(err)
+	}
+	r.f, r.size = f, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, errors.WithStack(err)
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.Rename(r.path, r.path+"."+time.Now().UTC().Format(auditBackupLayout)); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := r.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return errors.WithStack(err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// backups returns the paths of the rotated files, oldest first. Other files
+// with the same prefix, e.g. compressed copies, are ignored.
+func (r *rotatingFile) backups() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var backups []string
+	prefix := filepath.Base(r.path) + "."
+	for _, e := range entries {
+		suffix, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() {
+			continue
+		}
+		if _, err := time.Parse(auditBackupLayout, suffix); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(r.path), e.Name()))
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// lastAuditHash returns the hash of the last audit event in the file, or in
+// the most recent rotated file if the file is empty.
+func (r *rotatingFile) lastAuditHash() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := r.path
+	if r.size == 0 {
+		backups, err := r.backups()
+		if err != nil || len(backups) == 0 {
+			return "", err
+		}
+		path = backups[len(backups)-1]
+	}
+	return lastAuditHash(path)
+}
+
+// lastAuditHash returns the hash of the last audit event in the file, or an
+// empty string if it has none. Only the end of the file is read.
+func lastAuditHash(path string) (string, error) {
+	const tail = 1 << 20
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	offset := max(info.Size()-tail, 0)
+	b := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(b, offset); err != nil && !errors.Is(err, io.EOF) {
+		return "", errors.WithStack(err)
+	}
+
+	// Events start on a new line in both the "json" and the "json_pretty"
+	// format, in which nested values are indented.
+	start := bytes.LastIndex(b, []byte("\n{")) + 1
+	if start == 0 && (offset > 0 || !bytes.HasPrefix(b, []byte("{"))) {
+		return "", nil
+	}
+
+	var line struct {
+		Audit struct {
+			Hash string `json:"hash"`
+		} `json:"audit"`
+	}
+	if err := json.Unmarshal(b[start:], &line); err != nil {
+		return "", nil
+	}
+	return line.Audit.Hash, nil
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
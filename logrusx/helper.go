@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
@@ -20,11 +21,18 @@ import (
 type (
 	Logger struct {
 		*logrus.Entry
+		settings *atomic.Pointer[loggerSettings]
+		levels   *levelController
+		opts     []Option
+		name     string
+		version  string
+	}
+	// loggerSettings can be changed by UseConfig while the logger is in use.
+	// They are shared by all loggers derived from the same New call.
+	loggerSettings struct {
 		leakSensitive bool
 		redactionText string
-		opts          []Option
-		name          string
-		version       string
+		redactor      *redactor
 	}
 )
 
@@ -40,9 +48,14 @@ func (l *Logger) NewEntry() *Logger {
 	return &ll
 }
 
+// WithContext returns a logger for the context. If the context carries a span,
+// its trace and span IDs are added to the "otel" field.
 func (l *Logger) WithContext(ctx context.Context) *Logger {
 	ll := *l
 	ll.Entry = l.Logger.WithContext(ctx)
+	if traces := traceFields(trace.SpanContextFromContext(ctx)); traces != nil {
+		ll.Entry = ll.Entry.WithField("otel", traces)
+	}
 	return &ll
 }
 
@@ -59,7 +72,7 @@ func (l *Logger) HTTPHeadersRedacted(h http.Header) map[string]interface{} {
 				headers[keyLower] = l.maybeRedact(value)
 				continue
 			}
-			if l.leakSensitive {
+			if l.loadSettings().leakSensitive {
 				headers[keyLower] = locationURL.String()
 			} else {
 				locationURL.RawQuery = ""
@@ -99,15 +112,10 @@ func (l *Logger) WithRequest(r *http.Request) *Logger {
 	if !spanCtx.IsValid() {
 		_, _, spanCtx = otelhttptrace.Extract(r.Context(), r, opts)
 	}
-	if spanCtx.IsValid() {
-		traces := make(map[string]string, 2)
-		if spanCtx.HasTraceID() {
-			traces["trace_id"] = spanCtx.TraceID().String()
-		}
-		if spanCtx.HasSpanID() {
-			traces["span_id"] = spanCtx.SpanID().String()
-		}
+	if traces := traceFields(spanCtx); traces != nil {
 		ll = ll.WithField("otel", traces)
+		// Correlates exported log records with the span.
+		ll.Entry = ll.Entry.WithContext(trace.ContextWithSpanContext(r.Context(), spanCtx))
 	}
 	return ll
 }
@@ -118,26 +126,46 @@ func (l *Logger) Errorf(format string, args ...interface{}) {
 
 func (l *Logger) WithFields(f logrus.Fields) *Logger {
 	ll := *l
-	ll.Entry = l.Entry.WithFields(f)
+	ll.Entry = l.Entry.WithFields(l.redact(f))
 	return &ll
 }
 
 func (l *Logger) WithField(key string, value interface{}) *Logger {
 	ll := *l
-	ll.Entry = l.Entry.WithField(key, value)
+	ll.Entry = l.Entry.WithFields(l.redact(logrus.Fields{key: value}))
 	return &ll
 }
 
+// redact applies the redaction rules. If sensitive values may be leaked, only
+// the rules which drop values are applied.
+func (l *Logger) redact(f logrus.Fields) logrus.Fields {
+	s := l.loadSettings()
+	if s.leakSensitive {
+		return s.redactor.dropping().redact(f, s.redactionText)
+	}
+	return s.redactor.redact(f, s.redactionText)
+}
+
 func (l *Logger) maybeRedact(value interface{}) interface{} {
 	if fmt.Sprintf("%v", value) == "" || value == nil {
 		return nil
 	}
-	if !l.leakSensitive {
-		return l.redactionText
+	if s := l.loadSettings(); !s.leakSensitive {
+		return s.redactionText
 	}
 	return value
 }
 
+// loadSettings returns the settings in effect.
+func (l *Logger) loadSettings() *loggerSettings {
+	if l.settings != nil {
+		if s := l.settings.Load(); s != nil {
+			return s
+		}
+	}
+	return new(loggerSettings)
+}
+
 func (l *Logger) WithError(err error) *Logger {
 	if err == nil {
 		return l
@@ -0,0 +1,116 @@
+package logrusx
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ory/herodot"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LevelPath is the path where the log levels are conventionally managed.
+const LevelPath = "/admin/log/level"
+
+const (
+	// DefaultLevelTTL is how long a temporary level is in effect if the
+	// request does not set a TTL.
+	DefaultLevelTTL = 15 * time.Minute
+	// MaxLevelTTL is the longest TTL a temporary level may have.
+	MaxLevelTTL = 24 * time.Hour
+)
+
+var errMethodNotAllowed = herodot.DefaultError{
+	StatusField: http.StatusText(http.StatusMethodNotAllowed),
+	ErrorField:  "The request method is not allowed",
+	CodeField:   http.StatusMethodNotAllowed,
+}
+
+type levelRequest struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+	TTL    string `json:"ttl"`
+}
+
+// BearerTokenAuthenticator returns an authenticator for NewLevelHandler which
+// accepts requests with the token in the "Authorization: Bearer" header.
+func BearerTokenAuthenticator(token string) func(r *http.Request) error {
+	return func(r *http.Request) error {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			return errors.WithStack(herodot.ErrUnauthorized.WithReason("A valid bearer token is required to manage log levels."))
+		}
+		return nil
+	}
+}
+
+// NewLevelHandler returns a handler which changes the log levels at runtime:
+//
+//   - GET returns the levels in effect.
+//   - PUT temporarily sets the level of a module, or the global level if the
+//     module is empty, e.g. {"module": "popx", "level": "debug", "ttl": "10m"}.
+//     The level is reverted after the TTL, which defaults to DefaultLevelTTL.
+//   - DELETE reverts the temporary level of the module given in the "module"
+//     query parameter, or the temporary global level.
+//
+// Every request must pass the authenticator, and requests are rejected if it
+// is nil. Changes are logged at the warn level.
+func NewLevelHandler(l *Logger, writer herodot.Writer, authenticate func(r *http.Request) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authenticate == nil {
+			writer.WriteError(w, r, errors.WithStack(herodot.ErrUnauthorized.WithReason("Managing log levels is not enabled.")))
+			return
+		}
+		if err := authenticate(r); err != nil {
+			writer.WriteError(w, r, err)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			writer.Write(w, r, l.Levels())
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<10)).Decode(&req); err != nil {
+				writer.WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode the request body: %s", err)))
+				return
+			}
+
+			level, err := logrus.ParseLevel(req.Level)
+			if err != nil {
+				writer.WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unknown log level %q.", req.Level)))
+				return
+			}
+
+			ttl := DefaultLevelTTL
+			if req.TTL != "" {
+				if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 || ttl > MaxLevelTTL {
+					writer.WriteError(w, r, errors.WithStack(herodot.ErrBadRequest.WithReasonf("The TTL must be a positive duration of at most %s.", MaxLevelTTL)))
+					return
+				}
+			}
+
+			override, err := l.SetTemporaryLevel(req.Module, level, ttl)
+			if err != nil {
+				writer.WriteError(w, r, err)
+				return
+			}
+			l.WithRequest(r).WithField("level_override", override).Warn("The log level was changed temporarily.")
+			writer.Write(w, r, override)
+		case http.MethodDelete:
+			module := r.URL.Query().Get("module")
+			if err := l.RevertTemporaryLevel(module); err != nil {
+				writer.WriteError(w, r, err)
+				return
+			}
+			l.WithRequest(r).WithField("module", module).Warn("The temporary log level was reverted.")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT, DELETE")
+			writer.WriteError(w, r, errors.WithStack(errMethodNotAllowed))
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package logrusx
+
+import (
+	"encoding/json"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	// levelController manages the levels of the root logger and the module
+	// loggers created with WithModule. Levels are resolved in this order:
+	// temporary module level, temporary global level, configured module
+	// level, configured global level.
+	levelController struct {
+		mu           sync.Mutex
+		root         *logrus.Logger
+		out          *lockedWriter
+		reportCaller bool
+		global       logrus.Level
+		modules      map[string]logrus.Level
+		loggers      map[string]*logrus.Logger
+		temporary    map[string]*temporaryLevel
+	}
+	// lockedWriter serializes the writes of the root and the module loggers,
+	// which each only hold their own lock while writing.
+	lockedWriter struct {
+		mu sync.Mutex
+		w  io.Writer
+	}
+	temporaryLevel struct {
+		level     logrus.Level
+		expiresAt time.Time
+		timer     *time.Timer
+	}
+	// LevelOverride is a temporary log level. An empty module is the global
+	// level.
+	LevelOverride struct {
+		Module    string    `json:"module,omitempty"`
+		Level     string    `json:"level"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	// Levels are the log levels in effect.
+	Levels struct {
+		// Level is the configured global level.
+		Level string `json:"level"`
+		// Modules are the configured module levels.
+		Modules map[string]string `json:"modules"`
+		// Overrides are the temporary levels.
+		Overrides []LevelOverride `json:"overrides"`
+	}
+)
+
+var errNoLevelController = errors.New("the log levels can only be changed on loggers created with logrusx.New")
+
+func newLevelController(root *logrus.Logger, modules map[string]logrus.Level, reportCaller bool) *levelController {
+	c := &levelController{
+		root:         root,
+		reportCaller: reportCaller,
+		global:       root.GetLevel(),
+		modules:      modules,
+		loggers:      map[string]*logrus.Logger{},
+		temporary:    map[string]*temporaryLevel{},
+	}
+	c.output()
+	c.apply()
+	return c
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// moduleLevels returns the levels configured in "log.levels", e.g.
+// "log.levels.popx = debug". Unknown levels are ignored with a warning.
+func moduleLevels(l *logrus.Logger, c configurator) map[string]logrus.Level {
+	levels := map[string]logrus.Level{}
+	getter, ok := c.(interface{ Get(key string) interface{} })
+	if !ok {
+		return levels
+	}
+
+	var configured map[string]string
+	if raw := getter.Get("log.levels"); raw == nil {
+		return levels
+	} else if encoded, err := json.Marshal(raw); err != nil {
+		l.WithError(err).Warn("got invalid \"log.levels\", ignoring them")
+		return levels
+	} else if err := json.Unmarshal(encoded, &configured); err != nil {
+		l.WithError(err).Warn("got invalid \"log.levels\", ignoring them")
+		return levels
+	}
+
+	for module, level := range configured {
+		parsed, err := logrus.ParseLevel(level)
+		if err != nil {
+			l.WithError(err).Warnf("got unknown level for module %q in \"log.levels\", ignoring it", module)
+			continue
+		}
+		levels[module] = parsed
+	}
+	return levels
+}
+
+// configure replaces the formatter and the configured levels, e.g. after the
+// config was reloaded, and synchronizes the module loggers with the root
+// logger. Temporary levels stay in effect.
+func (c *levelController) configure(formatter logrus.Formatter, global logrus.Level, modules map[string]logrus.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.global, c.modules = global, modules
+	c.root.SetFormatter(formatter)
+	for _, l := range c.loggers {
+		l.SetFormatter(formatter)
+	}
+	c.output()
+	c.apply()
+}
+
+// output returns the writer shared by all loggers. If the output of the root
+// logger was replaced, it is wrapped again and the module loggers follow. It
+// must be called with the lock held.
+func (c *levelController) output() io.Writer {
+	if c.out != nil && c.root.Out == io.Writer(c.out) {
+		return c.out
+	}
+
+	c.out = &lockedWriter{w: c.root.Out}
+	c.root.SetOutput(c.out)
+	for _, l := range c.loggers {
+		l.SetOutput(c.out)
+	}
+	return c.out
+}
+
+// logger returns the logger of the module.
+func (c *levelController) logger(module string) *logrus.Logger {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if l, ok := c.loggers[module]; ok {
+		return l
+	}
+
+	hooks := make(logrus.LevelHooks, len(c.root.Hooks))
+	for level, h := range c.root.Hooks {
+		hooks[level] = slices.Clone(h)
+	}
+	l := &logrus.Logger{
+		Out:       c.output(),
+		Hooks:     hooks,
+		Formatter: c.root.Formatter,
+		ExitFunc:  c.root.ExitFunc,
+	}
+	c.setLevel(l, c.level(module))
+	c.loggers[module] = l
+	return l
+}
+
+// addHook adds the hook to the root and all module loggers.
+func (c *levelController) addHook(hook logrus.Hook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.root.AddHook(hook)
+	for _, l := range c.loggers {
+		l.AddHook(hook)
+	}
+}
+
+// setTemporary sets the level of the module, or the global level if the
+// module is empty, until the TTL expired.
+func (c *levelController) setTemporary(module string, level logrus.Level, ttl time.Duration) LevelOverride {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if previous, ok := c.temporary[module]; ok {
+		previous.timer.Stop()
+	}
+
+	t := &temporaryLevel{level: level, expiresAt: time.Now().Add(ttl).UTC()}
+	t.timer = time.AfterFunc(ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		// The override might have been replaced in the meantime.
+		if c.temporary[module] == t {
+			delete(c.temporary, module)
+			c.apply()
+		}
+	})
+	c.temporary[module] = t
+	c.apply()
+
+	return LevelOverride{Module: module, Level: level.String(), ExpiresAt: t.expiresAt}
+}
+
+// revert removes the temporary level of the module, or the temporary global
+// level if the module is empty.
+func (c *levelController) revert(module string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t, ok := c.temporary[module]; ok {
+		t.timer.Stop()
+		delete(c.temporary, module)
+		c.apply()
+	}
+}
+
+func (c *levelController) levels() Levels {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	levels := Levels{
+		Level:     c.global.String(),
+		Modules:   make(map[string]string, len(c.modules)),
+		Overrides: make([]LevelOverride, 0, len(c.temporary)),
+	}
+	for module, level := range c.modules {
+		levels.Modules[module] = level.String()
+	}
+	for module, t := range c.temporary {
+		levels.Overrides = append(levels.Overrides, LevelOverride{Module: module, Level: t.level.String(), ExpiresAt: t.expiresAt})
+	}
+	sort.Slice(levels.Overrides, func(i, j int) bool { return levels.Overrides[i].Module < levels.Overrides[j].Module })
+	return levels
+}
+
+// level returns the effective level of the module. It must be called with
+// the lock held.
+func (c *levelController) level(module string) logrus.Level {
+	if t, ok := c.temporary[module]; ok && module != "" {
+		return t.level
+	}
+	if t, ok := c.temporary[""]; ok {
+		return t.level
+	}
+	if level, ok := c.modules[module]; ok && module != "" {
+		return level
+	}
+	return c.global
+}
+
+// apply updates the level of all loggers. It must be called with the lock
+// held.
+func (c *levelController) apply() {
+	c.setLevel(c.root, c.level(""))
+	for module, l := range c.loggers {
+		c.setLevel(l, c.level(module))
+	}
+}
+
+// setLevel sets the level of the logger and reports the caller if enabled or
+// if the logger traces.
+func (c *levelController) setLevel(l *logrus.Logger, level logrus.Level) {
+	l.SetLevel(level)
+	l.SetReportCaller(c.reportCaller || level >= logrus.TraceLevel)
+}
+
+// WithModule returns a logger for the module whose level can be configured
+// separately with "log.levels.<module>" or changed at runtime with the level
+// handler. The module is added to the "module" field.
+func (l *Logger) WithModule(module string) *Logger {
+	if l.levels == nil || module == "" {
+		return l.WithField("module", module)
+	}
+
+	ll := *l
+	ll.Entry = l.Entry.Dup()
+	ll.Entry.Logger = l.levels.logger(module)
+	ll.Entry = ll.Entry.WithField("module", module)
+	return &ll
+}
+
+// AddHook adds the hook to the logger and its module loggers. Module loggers
+// copy the hooks of the root logger when they are created, so hooks added
+// directly to the logrus logger afterwards do not fire for modules.
+func (l *Logger) AddHook(hook logrus.Hook) {
+	if l.levels == nil {
+		l.Logger.AddHook(hook)
+		return
+	}
+	l.levels.addHook(hook)
+}
+
+// Levels returns the log levels in effect.
+func (l *Logger) Levels() Levels {
+	if l.levels == nil {
+		return Levels{Level: l.Logger.GetLevel().String(), Modules: map[string]string{}, Overrides: []LevelOverride{}}
+	}
+	return l.levels.levels()
+}
+
+// SetTemporaryLevel changes the level of the module, or the global level if
+// the module is empty, until the TTL expired. Setting a new temporary level
+// replaces the previous one.
+func (l *Logger) SetTemporaryLevel(module string, level logrus.Level, ttl time.Duration) (LevelOverride, error) {
+	if l.levels == nil {
+		return LevelOverride{}, errors.WithStack(errNoLevelController)
+	}
+	return l.levels.setTemporary(module, level, ttl), nil
+}
+
+// RevertTemporaryLevel reverts the temporary level of the module, or the
+// temporary global level if the module is empty.
+func (l *Logger) RevertTemporaryLevel(module string) error {
+	if l.levels == nil {
+		return errors.WithStack(errNoLevelController)
+	}
+	l.levels.revert(module)
+	return nil
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"sync/atomic"
 	"time"
 
 	gelf "github.com/seatgeek/logrus-gelf-formatter"
@@ -27,6 +29,12 @@ type (
 		redactionText string
 		hooks         []logrus.Hook
 		c             configurator
+
+		redactionRules   []RedactionRule
+		redactionHashKey []byte
+
+		auditOutput    io.Writer
+		auditHashChain bool
 	}
 	Option           func(*options)
 	nullConfigurator struct{}
@@ -66,52 +74,65 @@ func newLogger(parent *logrus.Logger, o *options) *logrus.Logger {
 	setLevel(l, o)
 	setFormatter(l, o)
 
-	l.ReportCaller = o.reportCaller || l.IsLevelEnabled(logrus.TraceLevel)
+	l.SetReportCaller(o.reportCaller || l.IsLevelEnabled(logrus.TraceLevel))
 	return l
 }
 
 func setLevel(l *logrus.Logger, o *options) {
+	l.SetLevel(levelFromOptions(o))
+}
+
+func levelFromOptions(o *options) logrus.Level {
 	if o.level != nil {
-		l.Level = *o.level
-	} else {
-		var err error
-		l.Level, err = logrus.ParseLevel(cmp.Or(
-			o.c.String("log.level"),
-			os.Getenv("LOG_LEVEL")))
-		if err != nil {
-			l.Level = logrus.InfoLevel
-		}
+		return *o.level
+	}
+	level, err := logrus.ParseLevel(cmp.Or(
+		o.c.String("log.level"),
+		os.Getenv("LOG_LEVEL")))
+	if err != nil {
+		return logrus.InfoLevel
 	}
+	return level
 }
 
 func setFormatter(l *logrus.Logger, o *options) {
+	formatter, err := formatterFromOptions(o)
+	// we first have to set the formatter before we can complain about the unknown format
+	l.SetFormatter(formatter)
+	warnUnknownFormat(l, err)
+}
+
+// formatterFromOptions returns the configured formatter. If the format is
+// unknown, the text formatter is returned together with an error.
+func formatterFromOptions(o *options) (logrus.Formatter, error) {
 	if o.formatter != nil {
-		l.Formatter = o.formatter
-	} else {
-		var unknownFormat bool // we first have to set the formatter before we can complain about the unknown format
-
-		format := stringsx.SwitchExact(cmp.Or(o.format, o.c.String("log.format"), os.Getenv("LOG_FORMAT")))
-		switch {
-		case format.AddCase("json"):
-			l.Formatter = &logrus.JSONFormatter{PrettyPrint: false, TimestampFormat: time.RFC3339Nano, DisableHTMLEscape: true}
-		case format.AddCase("json_pretty"):
-			l.Formatter = &logrus.JSONFormatter{PrettyPrint: true, TimestampFormat: time.RFC3339Nano, DisableHTMLEscape: true}
-		case format.AddCase("gelf"):
-			l.Formatter = new(gelf.GelfFormatter)
-		default:
-			unknownFormat = true
-			fallthrough
-		case format.AddCase("text", ""):
-			l.Formatter = &logrus.TextFormatter{
-				DisableQuote:     true,
-				DisableTimestamp: false,
-				FullTimestamp:    true,
-			}
-		}
-
-		if unknownFormat {
-			l.WithError(format.ToUnknownCaseErr()).Warn("got unknown \"log.format\", falling back to \"text\"")
-		}
+		return o.formatter, nil
+	}
+
+	format := stringsx.SwitchExact(cmp.Or(o.format, o.c.String("log.format"), os.Getenv("LOG_FORMAT")))
+	switch {
+	case format.AddCase("json"):
+		return &logrus.JSONFormatter{PrettyPrint: false, TimestampFormat: time.RFC3339Nano, DisableHTMLEscape: true}, nil
+	case format.AddCase("json_pretty"):
+		return &logrus.JSONFormatter{PrettyPrint: true, TimestampFormat: time.RFC3339Nano, DisableHTMLEscape: true}, nil
+	case format.AddCase("gelf"):
+		return new(gelf.GelfFormatter), nil
+	}
+
+	text := &logrus.TextFormatter{
+		DisableQuote:     true,
+		DisableTimestamp: false,
+		FullTimestamp:    true,
+	}
+	if format.AddCase("text", "") {
+		return text, nil
+	}
+	return text, format.ToUnknownCaseErr()
+}
+
+func warnUnknownFormat(l *logrus.Logger, err error) {
+	if err != nil {
+		l.WithError(err).Warn("got unknown \"log.format\", falling back to \"text\"")
 	}
 }
 
@@ -153,23 +174,52 @@ func newOptions(opts []Option) *options {
 // New creates a new logger with all the important fields set.
 func New(name string, version string, opts ...Option) *Logger {
 	o := newOptions(opts)
+	l := newLogger(o.l, o)
+
+	settings := new(atomic.Pointer[loggerSettings])
+	settings.Store(newLoggerSettings(l, o))
 	return &Logger{
-		opts:          opts,
-		name:          name,
-		version:       version,
-		leakSensitive: o.leakSensitive || o.c.Bool("log.leak_sensitive_values"),
-		redactionText: cmp.Or(o.redactionText, `Value is sensitive and has been redacted. To see the value set config key "log.leak_sensitive_values = true" or environment variable "LOG_LEAK_SENSITIVE_VALUES=true".`),
-		Entry: newLogger(o.l, o).WithFields(logrus.Fields{
+		opts:     opts,
+		name:     name,
+		version:  version,
+		settings: settings,
+		levels:   newLevelController(l, moduleLevels(l, o.c), o.reportCaller),
+		Entry: l.WithFields(logrus.Fields{
 			"audience": "application", "service_name": name, "service_version": version}),
 	}
 }
 
+// UseConfig applies the "log" config to the logger, including the levels of
+// module loggers. It is safe to call again whenever the config was reloaded,
+// also while the logger is in use, see configx.WithLogrusConfig.
 func (l *Logger) UseConfig(c configurator) {
-	l.leakSensitive = l.leakSensitive || c.Bool("log.leak_sensitive_values")
-	l.redactionText = cmp.Or(c.String("log.redaction_text"), l.redactionText)
-	o := newOptions(append(l.opts, WithConfigurator(c)))
-	setLevel(l.Entry.Logger, o)
-	setFormatter(l.Entry.Logger, o)
+	o := newOptions(append(slices.Clip(l.opts), WithConfigurator(c)))
+	root := l.Entry.Logger
+	if l.levels != nil {
+		root = l.levels.root
+		formatter, err := formatterFromOptions(o)
+		l.levels.configure(formatter, levelFromOptions(o), moduleLevels(root, c))
+		warnUnknownFormat(root, err)
+	} else {
+		setLevel(root, o)
+		setFormatter(root, o)
+	}
+
+	if l.settings == nil {
+		l.settings = new(atomic.Pointer[loggerSettings])
+	}
+	l.settings.Store(newLoggerSettings(root, o))
+}
+
+// newLoggerSettings returns the settings of the options and config. Like the
+// level, they are derived from scratch whenever the config is applied, so
+// that e.g. disabling "log.leak_sensitive_values" takes effect immediately.
+func newLoggerSettings(l *logrus.Logger, o *options) *loggerSettings {
+	return &loggerSettings{
+		leakSensitive: o.leakSensitive || o.c.Bool("log.leak_sensitive_values"),
+		redactionText: cmp.Or(o.redactionText, o.c.String("log.redaction_text"), `Value is sensitive and has been redacted. To see the value set config key "log.leak_sensitive_values = true" or environment variable "LOG_LEAK_SENSITIVE_VALUES=true".`),
+		redactor:      newRedactor(l, o),
+	}
 }
 
 func (l *Logger) ReportError(r *http.Request, code int, err error, args ...interface{}) {
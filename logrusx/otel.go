@@ -0,0 +1,209 @@
+package logrusx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	otelScopeName = "github.com/huanggze/x/logrusx"
+	// otelFlushTimeout bounds flushing the logger provider before the process
+	// exits or panics.
+	otelFlushTimeout = 5 * time.Second
+)
+
+// traceFields returns the "otel" field value for the span context, or nil if
+// the span context is invalid.
+func traceFields(spanCtx trace.SpanContext) map[string]string {
+	if !spanCtx.IsValid() {
+		return nil
+	}
+
+	traces := make(map[string]string, 2)
+	if spanCtx.HasTraceID() {
+		traces["trace_id"] = spanCtx.TraceID().String()
+	}
+	if spanCtx.HasSpanID() {
+		traces["span_id"] = spanCtx.SpanID().String()
+	}
+	return traces
+}
+
+type (
+	otelHook struct {
+		logger  log.Logger
+		flusher otelFlusher
+	}
+	otelFlusher interface {
+		ForceFlush(ctx context.Context) error
+	}
+)
+
+// NewOTelHook returns a hook which exports log entries through the
+// OpenTelemetry logger provider, e.g. to an OTLP log exporter. Entries logged
+// with a context are correlated with its span.
+//
+// If the provider can be flushed, like the SDK logger provider, it is flushed
+// after fatal and panic entries, because the process exits or panics right
+// after. Otherwise, the provider must be shut down to export buffered records.
+func NewOTelHook(provider log.LoggerProvider) logrus.Hook {
+	h := &otelHook{logger: provider.Logger(otelScopeName)}
+	h.flusher, _ = provider.(otelFlusher)
+	return h
+}
+
+func (h *otelHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *otelHook) Fire(entry *logrus.Entry) error {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var r log.Record
+	r.SetTimestamp(entry.Time)
+	r.SetObservedTimestamp(time.Now())
+	r.SetSeverity(otelSeverity(entry.Level))
+	r.SetSeverityText(entry.Level.String())
+	r.SetBody(log.StringValue(entry.Message))
+
+	attrs := make([]log.KeyValue, 0, len(entry.Data)+3)
+	for key, value := range entry.Data {
+		if key == "otel" {
+			// The span is taken from the context.
+			continue
+		}
+		attrs = append(attrs, log.KeyValue{Key: key, Value: otelValue(value)})
+	}
+	if entry.HasCaller() {
+		attrs = append(attrs,
+			log.String("code.function", entry.Caller.Function),
+			log.String("code.filepath", entry.Caller.File),
+			log.Int("code.lineno", entry.Caller.Line))
+	}
+	r.AddAttributes(attrs...)
+
+	h.logger.Emit(ctx, r)
+
+	if h.flusher != nil && entry.Level <= logrus.FatalLevel {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), otelFlushTimeout)
+		defer cancel()
+		return h.flusher.ForceFlush(ctx)
+	}
+	return nil
+}
+
+func otelSeverity(level logrus.Level) log.Severity {
+	switch level {
+	case logrus.TraceLevel:
+		return log.SeverityTrace
+	case logrus.DebugLevel:
+		return log.SeverityDebug
+	case logrus.InfoLevel:
+		return log.SeverityInfo
+	case logrus.WarnLevel:
+		return log.SeverityWarn
+	case logrus.ErrorLevel:
+		return log.SeverityError
+	case logrus.FatalLevel:
+		return log.SeverityFatal
+	case logrus.PanicLevel:
+		return log.SeverityFatal4
+	}
+	return log.SeverityUndefined
+}
+
+func otelValue(value interface{}) log.Value {
+	switch v := value.(type) {
+	case nil:
+		return log.Value{}
+	case string:
+		return log.StringValue(v)
+	case bool:
+		return log.BoolValue(v)
+	case int:
+		return log.IntValue(v)
+	case int8:
+		return log.Int64Value(int64(v))
+	case int16:
+		return log.Int64Value(int64(v))
+	case int32:
+		return log.Int64Value(int64(v))
+	case int64:
+		return log.Int64Value(v)
+	case uint8:
+		return log.Int64Value(int64(v))
+	case uint16:
+		return log.Int64Value(int64(v))
+	case uint32:
+		return log.Int64Value(int64(v))
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return log.Int64Value(int64(v))
+		}
+		return log.StringValue(fmt.Sprintf("%d", v))
+	case uint64:
+		if v <= math.MaxInt64 {
+			return log.Int64Value(int64(v))
+		}
+		return log.StringValue(fmt.Sprintf("%d", v))
+	case float32:
+		return log.Float64Value(float64(v))
+	case float64:
+		return log.Float64Value(v)
+	case []byte:
+		return log.BytesValue(v)
+	case time.Time:
+		return log.StringValue(v.Format(time.RFC3339Nano))
+	case time.Duration:
+		return log.StringValue(v.String())
+	case error:
+		return log.StringValue(v.Error())
+	case logrus.Fields:
+		return otelMapValue(v)
+	case map[string]interface{}:
+		return otelMapValue(v)
+	case map[string]string:
+		kvs := make([]log.KeyValue, 0, len(v))
+		for key, value := range v {
+			kvs = append(kvs, log.String(key, value))
+		}
+		return log.MapValue(kvs...)
+	case []interface{}:
+		values := make([]log.Value, len(v))
+		for k, e := range v {
+			values[k] = otelValue(e)
+		}
+		return log.SliceValue(values...)
+	case []string:
+		values := make([]log.Value, len(v))
+		for k, e := range v {
+			values[k] = log.StringValue(e)
+		}
+		return log.SliceValue(values...)
+	case fmt.Stringer:
+		return log.StringValue(v.String())
+	}
+
+	if encoded, err := json.Marshal(value); err == nil {
+		return log.StringValue(string(encoded))
+	}
+	return log.StringValue(fmt.Sprintf("%v", value))
+}
+
+func otelMapValue(m map[string]interface{}) log.Value {
+	kvs := make([]log.KeyValue, 0, len(m))
+	for key, value := range m {
+		kvs = append(kvs, log.KeyValue{Key: key, Value: otelValue(value)})
+	}
+	return log.MapValue(kvs...)
+}
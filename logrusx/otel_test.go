@@ -0,0 +1,112 @@
+package logrusx
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/log"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type memoryExporter struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+func (e *memoryExporter) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+func (e *memoryExporter) Shutdown(context.Context) error   { return nil }
+func (e *memoryExporter) ForceFlush(context.Context) error { return nil }
+
+func (e *memoryExporter) exported() []sdklog.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+func attributes(r sdklog.Record) map[string]log.Value {
+	attrs := map[string]log.Value{}
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
+
+func TestOTelHook(t *testing.T) {
+	exporter := new(memoryExporter)
+	// The export interval is long enough that records are only exported when
+	// the provider is flushed.
+	provider := sdklog.NewLoggerProvider(sdklog.WithProcessor(
+		sdklog.NewBatchProcessor(exporter, sdklog.WithExportInterval(time.Hour))))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	root := logrus.New()
+	root.Out = new(safeDiscard)
+	var exitCode int
+	root.ExitFunc = func(code int) { exitCode = code }
+
+	l := New("test", "v0.0.0", UseLogger(root))
+	module := l.WithModule("popx")
+	l.AddHook(NewOTelHook(provider))
+
+	spanCtx := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x01},
+		SpanID:     trace.SpanID{0x02},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), spanCtx)
+
+	l.WithContext(ctx).WithField("foo", "bar").Info("hello")
+	module.Warn("from a module")
+	assert.Empty(t, exporter.exported(), "records are batched until flushed")
+
+	l.Fatal("bye")
+	assert.Equal(t, 1, exitCode)
+
+	records := exporter.exported()
+	require.Len(t, records, 3, "fatal entries flush the provider")
+
+	t.Run("case=correlates with the span", func(t *testing.T) {
+		r := records[0]
+		assert.Equal(t, "hello", r.Body().AsString())
+		assert.Equal(t, log.SeverityInfo, r.Severity())
+		assert.Equal(t, spanCtx.TraceID(), r.TraceID())
+		assert.Equal(t, spanCtx.SpanID(), r.SpanID())
+
+		attrs := attributes(r)
+		assert.Equal(t, "bar", attrs["foo"].AsString())
+		assert.NotContains(t, attrs, "otel")
+	})
+
+	t.Run("case=exports module entries", func(t *testing.T) {
+		r := records[1]
+		assert.Equal(t, "from a module", r.Body().AsString())
+		assert.Equal(t, log.SeverityWarn, r.Severity())
+		assert.Equal(t, "popx", attributes(r)["module"].AsString())
+	})
+
+	t.Run("case=exports fatal entries", func(t *testing.T) {
+		r := records[2]
+		assert.Equal(t, "bye", r.Body().AsString())
+		assert.Equal(t, log.SeverityFatal, r.Severity())
+		assert.False(t, r.TraceID().IsValid())
+	})
+}
+
+type safeDiscard struct{}
+
+func (safeDiscard) Write(p []byte) (int, error) { return len(p), nil }
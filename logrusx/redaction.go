@@ -0,0 +1,307 @@
+package logrusx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RedactionMode is how a redaction rule redacts a value.
+type RedactionMode string
+
+const (
+	// RedactionModeMask replaces the value, or the part matching a value
+	// pattern, with the replacement text.
+	RedactionModeMask RedactionMode = "mask"
+	// RedactionModeHash replaces the value, or the part matching a value
+	// pattern, with its HMAC-SHA256 under the redaction hash key, which
+	// allows correlating log entries without revealing the value. Without a
+	// hash key, the plain SHA-256 hash is used, which can be reversed by
+	// brute force for low-entropy values such as emails or phone numbers.
+	RedactionModeHash RedactionMode = "hash"
+	// RedactionModeDrop removes the field. Unlike the other modes, it also
+	// applies if leaking sensitive values is enabled.
+	RedactionModeDrop RedactionMode = "drop"
+)
+
+// RedactionRule redacts log fields by name or by value.
+type RedactionRule struct {
+	// Fields are case-insensitive glob patterns, e.g. "password" or
+	// "*_token", matched against field names at any depth.
+	Fields []string `json:"fields,omitempty"`
+	// Values are regular expressions matched against string values, e.g.
+	// emails or card numbers.
+	Values []string `json:"values,omitempty"`
+	// Mode is how to redact matches. It defaults to RedactionModeMask.
+	Mode RedactionMode `json:"mode,omitempty"`
+	// Replacement is the text masked values are replaced with. It defaults to
+	// the redaction text of the logger.
+	Replacement string `json:"replacement,omitempty"`
+}
+
+type (
+	redactor struct {
+		rules []compiledRedactionRule
+		// drop has only the rules of this redactor which drop values.
+		drop *redactor
+	}
+	compiledRedactionRule struct {
+		RedactionRule
+		values  []*regexp.Regexp
+		hashKey []byte
+	}
+)
+
+// WithRedactionRules sets the redaction rules, overriding the
+// "log.redaction.rules" config key.
+func WithRedactionRules(rules ...RedactionRule) Option {
+	return func(o *options) {
+		o.redactionRules = rules
+	}
+}
+
+// WithRedactionHashKey sets the key of the HMAC used by RedactionModeHash,
+// overriding the "log.redaction.hash_key" config key.
+func WithRedactionHashKey(key []byte) Option {
+	return func(o *options) {
+		o.redactionHashKey = key
+	}
+}
+
+func newRedactor(l *logrus.Logger, o *options) *redactor {
+	rules := o.redactionRules
+	if rules == nil {
+		var err error
+		if rules, err = redactionRulesFromConfig(o.c); err != nil {
+			l.WithError(err).Warn("got invalid \"log.redaction.rules\", ignoring them")
+		}
+	}
+
+	hashKey := o.redactionHashKey
+	if len(hashKey) == 0 {
+		hashKey = []byte(o.c.String("log.redaction.hash_key"))
+	}
+
+	r := new(redactor)
+	for k, rule := range rules {
+		compiled := compiledRedactionRule{RedactionRule: rule, hashKey: hashKey}
+		compiled.Mode = RedactionMode(strings.ToLower(string(rule.Mode)))
+		switch compiled.Mode {
+		case "":
+			compiled.Mode = RedactionModeMask
+		case RedactionModeMask, RedactionModeHash, RedactionModeDrop:
+		default:
+			l.Warnf("got unknown mode %q in redaction rule %d, ignoring the rule", rule.Mode, k)
+			continue
+		}
+
+		valid := true
+		for _, pattern := range rule.Fields {
+			if _, err := path.Match(pattern, ""); err != nil {
+				l.WithError(errors.WithStack(err)).Warnf("got invalid field pattern %q in redaction rule %d, ignoring the rule", pattern, k)
+				valid = false
+			}
+		}
+		for _, pattern := range rule.Values {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				l.WithError(errors.WithStack(err)).Warnf("got invalid value pattern %q in redaction rule %d, ignoring the rule", pattern, k)
+				valid = false
+				continue
+			}
+			compiled.values = append(compiled.values, re)
+		}
+		if valid {
+			r.rules = append(r.rules, compiled)
+		}
+	}
+
+	r.drop = new(redactor)
+	var hashes bool
+	for _, rule := range r.rules {
+		switch rule.Mode {
+		case RedactionModeDrop:
+			r.drop.rules = append(r.drop.rules, rule)
+		case RedactionModeHash:
+			hashes = true
+		}
+	}
+	if hashes && len(hashKey) == 0 {
+		l.Warn("no \"log.redaction.hash_key\" is set, hashed log values are unkeyed and low-entropy values can be recovered from them")
+	}
+	return r
+}
+
+func redactionRulesFromConfig(c configurator) ([]RedactionRule, error) {
+	getter, ok := c.(interface{ Get(key string) interface{} })
+	if !ok {
+		return nil, nil
+	}
+	raw := getter.Get("log.redaction.rules")
+	if raw == nil {
+		return nil, nil
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var rules []RedactionRule
+	if err := json.Unmarshal(encoded, &rules); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return rules, nil
+}
+
+// dropping returns a redactor with only the rules which drop values.
+func (r *redactor) dropping() *redactor {
+	if r == nil {
+		return nil
+	}
+	return r.drop
+}
+
+// redact returns a copy of the fields with the rules applied.
+func (r *redactor) redact(fields logrus.Fields, text string) logrus.Fields {
+	if r == nil || len(r.rules) == 0 {
+		return fields
+	}
+
+	redacted := make(logrus.Fields, len(fields))
+	for key, value := range fields {
+		if value, keep := r.redactField(key, value, text); keep {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// redactField returns the redacted value of the field, and false if the field
+// must be dropped.
+func (r *redactor) redactField(key string, value interface{}, text string) (interface{}, bool) {
+	name := strings.ToLower(key)
+	for _, rule := range r.rules {
+		for _, pattern := range rule.Fields {
+			if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+				return rule.apply(value, text)
+			}
+		}
+	}
+	return r.redactValue(value, text)
+}
+
+func (r *redactor) redactValue(value interface{}, text string) (interface{}, bool) {
+	switch v := value.(type) {
+	case logrus.Fields:
+		return r.redactMap(v, text), true
+	case map[string]interface{}:
+		return map[string]interface{}(r.redactMap(v, text)), true
+	case map[string]string:
+		redacted := make(map[string]string, len(v))
+		for key, value := range v {
+			if value, keep := r.redactField(key, value, text); keep {
+				redacted[key] = fmt.Sprintf("%v", value)
+			}
+		}
+		return redacted, true
+	case []interface{}:
+		redacted := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			if e, keep := r.redactValue(e, text); keep {
+				redacted = append(redacted, e)
+			}
+		}
+		return redacted, true
+	case []string:
+		redacted := make([]string, 0, len(v))
+		for _, e := range v {
+			if e, keep := r.redactString(e, text); keep {
+				redacted = append(redacted, e)
+			}
+		}
+		return redacted, true
+	case string:
+		return r.redactString(v, text)
+	case error:
+		// Only replace errors if they contain sensitive values, to keep
+		// their stack traces otherwise.
+		if redacted, keep := r.redactString(v.Error(), text); !keep || redacted != v.Error() {
+			return redacted, keep
+		}
+	case fmt.Stringer:
+		if redacted, keep := r.redactString(v.String(), text); !keep || redacted != v.String() {
+			return redacted, keep
+		}
+	}
+	return value, true
+}
+
+func (r *redactor) redactMap(m map[string]interface{}, text string) logrus.Fields {
+	redacted := make(logrus.Fields, len(m))
+	for key, value := range m {
+		if value, keep := r.redactField(key, value, text); keep {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+func (r *redactor) redactString(s, text string) (string, bool) {
+	for _, rule := range r.rules {
+		for _, re := range rule.values {
+			if !re.MatchString(s) {
+				continue
+			}
+			if rule.Mode == RedactionModeDrop {
+				return "", false
+			}
+			s = re.ReplaceAllStringFunc(s, func(match string) string {
+				return rule.replace(match, text)
+			})
+		}
+	}
+	return s, true
+}
+
+// apply redacts a value whose field name matched the rule.
+func (rule compiledRedactionRule) apply(value interface{}, text string) (interface{}, bool) {
+	if rule.Mode == RedactionModeDrop {
+		return nil, false
+	}
+	if value == nil {
+		return nil, true
+	}
+	if s, ok := value.(string); ok {
+		return rule.replace(s, text), true
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("%v", value))
+	}
+	return rule.replace(string(encoded), text), true
+}
+
+func (rule compiledRedactionRule) replace(value, text string) string {
+	if rule.Mode == RedactionModeHash {
+		if len(rule.hashKey) == 0 {
+			sum := sha256.Sum256([]byte(value))
+			return "sha256:" + hex.EncodeToString(sum[:])
+		}
+		mac := hmac.New(sha256.New, rule.hashKey)
+		_, _ = mac.Write([]byte(value))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+	}
+	if rule.Replacement != "" {
+		return rule.Replacement
+	}
+	return text
+}
@@ -21,6 +21,7 @@ type OTLPConfig struct {
 	Insecure            bool         `json:"insecure"`
 	Sampling            OTLPSampling `json:"sampling"`
 	AuthorizationHeader string       `json:"authorization_header"`
+	ExportLogs          bool         `json:"export_logs"`
 }
 
 type JaegerSampling struct {
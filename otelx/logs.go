@@ -0,0 +1,51 @@
+package otelx
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
+)
+
+// newResource returns the resource shared by traces and logs, so that they
+// can be joined.
+func newResource(c *Config) *resource.Resource {
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(c.ServiceName),
+		semconv.DeploymentEnvironmentName(c.DeploymentEnvironment),
+	)
+}
+
+// SetupOTLPLogs configures and returns a logger provider which exports log
+// records to the OTLP server configured in [otelx.OTLPConfig], using the same
+// resource as the tracer provider.
+//
+// The logger provider must be shut down to flush buffered records.
+func SetupOTLPLogs(c *Config) (*sdklog.LoggerProvider, error) {
+	clientOpts := []otlploghttp.Option{
+		otlploghttp.WithEndpoint(c.Providers.OTLP.ServerURL),
+	}
+
+	if c.Providers.OTLP.Insecure {
+		clientOpts = append(clientOpts, otlploghttp.WithInsecure())
+	}
+
+	if c.Providers.OTLP.AuthorizationHeader != "" {
+		clientOpts = append(clientOpts,
+			otlploghttp.WithHeaders(map[string]string{"Authorization": c.Providers.OTLP.AuthorizationHeader}),
+		)
+	}
+
+	exp, err := otlploghttp.New(context.Background(), clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exp)),
+		sdklog.WithResource(newResource(c)),
+	), nil
+}
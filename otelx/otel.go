@@ -1,6 +1,11 @@
 package otelx
 
 import (
+	"context"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	"go.opentelemetry.io/otel/trace"
 	"go.opentelemetry.io/otel/trace/embedded"
 	"go.opentelemetry.io/otel/trace/noop"
@@ -11,6 +16,7 @@ import (
 
 type Tracer struct {
 	tracer trace.Tracer
+	logs   *sdklog.LoggerProvider
 }
 
 // Creates a new tracer. If name is empty, a default tracer name is used
@@ -59,6 +65,18 @@ func (t *Tracer) setup(name string, l *logrusx.Logger, c *Config) error {
 
 		t.tracer = tracer
 		l.Infof("OTLP tracer configured! Sending spans to %s", c.Providers.OTLP.ServerURL)
+
+		if c.Providers.OTLP.ExportLogs {
+			logs, err := SetupOTLPLogs(c)
+			if err != nil {
+				return err
+			}
+
+			t.logs = logs
+			global.SetLoggerProvider(logs)
+			l.AddHook(logrusx.NewOTelHook(logs))
+			l.Infof("OTLP log exporter configured! Sending logs to %s", c.Providers.OTLP.ServerURL)
+		}
 	case f.AddCase(""):
 		l.Infof("No tracer configured - skipping tracing setup")
 		t.tracer = noop.NewTracerProvider().Tracer(name)
@@ -82,6 +100,23 @@ func (t *Tracer) Tracer() trace.Tracer {
 	return t.tracer
 }
 
+// LoggerProvider returns the OpenTelemetry logger provider which exports logs
+// alongside the traces, or nil if log export is not configured. It must be
+// shut down to flush buffered log records, see Shutdown.
+func (t *Tracer) LoggerProvider() *sdklog.LoggerProvider {
+	return t.logs
+}
+
+// Shutdown flushes buffered log records and shuts down the log exporter. It
+// should be called before the process exits. Logs must not be exported
+// afterwards.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.logs == nil {
+		return nil
+	}
+	return errors.WithStack(t.logs.Shutdown(ctx))
+}
+
 // Provider returns a TracerProvider which in turn yields this tracer unmodified.
 func (t *Tracer) Provider() trace.TracerProvider {
 	return tracerProvider{t: t.Tracer()}
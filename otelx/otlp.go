@@ -9,9 +9,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/jaeger"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -41,10 +39,7 @@ func SetupJaeger(t *Tracer, tracerName string, c *Config) (trace.Tracer, error)
 
 	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(c.ServiceName),
-			semconv.DeploymentEnvironmentName(c.DeploymentEnvironment))),
+		sdktrace.WithResource(newResource(c)),
 	}
 
 	samplingServerURL := c.Providers.Jaeger.Sampling.ServerURL
@@ -3,9 +3,7 @@ package otelx
 import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/zipkin"
-	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -17,11 +15,7 @@ func SetupZipkin(t *Tracer, tracerName string, c *Config) (trace.Tracer, error)
 
 	tpOpts := []sdktrace.TracerProviderOption{
 		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(c.ServiceName),
-			semconv.DeploymentEnvironmentName(c.DeploymentEnvironment),
-		)),
+		sdktrace.WithResource(newResource(c)),
 		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(
 			c.Providers.Zipkin.Sampling.SamplingRatio,
 		))),
@@ -0,0 +1,59 @@
+package randx
+
+import (
+	"math/rand/v2"
+)
+
+// Seeded is a deterministic random number generator. The same seed always
+// yields the same sequence, which makes it useful for reproducible test data.
+//
+// Seeded is not cryptographically secure and must not be used for secrets.
+// It is not safe for concurrent use.
+type Seeded struct {
+	r *rand.Rand
+}
+
+// NewSeeded returns a Seeded generator.
+func NewSeeded(seed uint64) *Seeded {
+	return &Seeded{r: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// IntN returns a random int in [0,n). It panics if n <= 0.
+func (s *Seeded) IntN(n int) int {
+	return s.r.IntN(n)
+}
+
+// Int64Range returns a random int64 in [min,max].
+func (s *Seeded) Int64Range(min, max int64) int64 {
+	if max <= min {
+		return min
+	}
+	if n := max - min + 1; n > 0 {
+		return min + s.r.Int64N(n)
+	}
+	// The range overflows int64.
+	for {
+		if v := int64(s.r.Uint64()); v >= min && v <= max {
+			return v
+		}
+	}
+}
+
+// Float64 returns a random float64 in [0.0,1.0).
+func (s *Seeded) Float64() float64 {
+	return s.r.Float64()
+}
+
+// Bool returns a random bool.
+func (s *Seeded) Bool() bool {
+	return s.r.IntN(2) == 0
+}
+
+// String returns a random string of length l using the allowed runes.
+func (s *Seeded) String(l int, allowedRunes []rune) string {
+	seq := make([]rune, l)
+	for i := range seq {
+		seq[i] = allowedRunes[s.r.IntN(len(allowedRunes))]
+	}
+	return string(seq)
+}
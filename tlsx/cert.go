@@ -50,6 +50,40 @@ func GetCertificate(
 	certPath, keyPath string,
 	errs chan<- error,
 ) (func(*tls.ClientHelloInfo) (*tls.Certificate, error), error) {
+	load, err := watchCertificate(ctx, certPath, keyPath, errs)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		return load()
+	}, nil
+}
+
+// GetClientCertificate returns a function for use with
+// "net/tls".Config.GetClientCertificate to present a client certificate in
+// mutual TLS.
+//
+// The certificate is reloaded in the background like in GetCertificate; the
+// CertificateRequestInfo is unused.
+func GetClientCertificate(
+	ctx context.Context,
+	certPath, keyPath string,
+	errs chan<- error,
+) (func(*tls.CertificateRequestInfo) (*tls.Certificate, error), error) {
+	load, err := watchCertificate(ctx, certPath, keyPath, errs)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return load()
+	}, nil
+}
+
+func watchCertificate(
+	ctx context.Context,
+	certPath, keyPath string,
+	errs chan<- error,
+) (func() (*tls.Certificate, error), error) {
 	if certPath == "" || keyPath == "" {
 		return nil, errors.WithStack(ErrNoCertificatesConfigured)
 	}
@@ -109,7 +143,7 @@ func GetCertificate(
 		}
 	}()
 
-	return func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func() (*tls.Certificate, error) {
 		if cert, ok := store.Load().(*tls.Certificate); ok {
 			return cert, nil
 		}